@@ -4,15 +4,64 @@
 
 // autogzip provides on-the-fly gzip encoding for http servers. It also has
 // a client that decodes automatically when necessary (GetPage à-la Twisted).
+//
+// Only responses of at least MinSize bytes and with a compressible
+// Content-Type are compressed. Smaller responses are sent as is, because
+// compressing them wastes CPU for little or no gain. Partial content, as
+// sent by http.ServeFile and http.ServeContent for range requests, is
+// never compressed because Content-Range refers to the original bytes.
+//
+// Responses are compressed with the coding preferred by the client in
+// Accept-Encoding, gzip or any other registered with RegisterEncoding,
+// like Brotli when building with the brotli build tag.
 package autogzip
 
 import (
+	"bufio"
 	"compress/gzip"
+	"errors"
 	"io"
+	"mime"
+	"net"
 	"net/http"
 	"strings"
 )
 
+// ErrNoHijack is returned by the Hijack method of the writers of Handle
+// and HandleFunc when the underlying connection can't be hijacked.
+var ErrNoHijack = errors.New("autogzip: server does not support hijacking")
+
+// MinSize is the minimum size of a response body, in bytes, for it to be
+// compressed.
+var MinSize = 1024
+
+// CompressibleTypes are the media types eligible for compression, in
+// addition to all text/* types and the +json and +xml suffixes.
+var CompressibleTypes = map[string]bool{
+	"application/javascript":   true,
+	"application/json":         true,
+	"application/x-javascript": true,
+	"application/xml":          true,
+	"image/svg+xml":            true,
+}
+
+// compressible checks whether the given Content-Type is eligible for
+// compression.
+func compressible(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(t, "text/") ||
+		strings.HasSuffix(t, "+json") ||
+		strings.HasSuffix(t, "+xml") ||
+		CompressibleTypes[t]
+}
+
+// ResponseWriter writes the response body to Writer, like a gzip.Writer,
+// and everything else to the embedded http.ResponseWriter, for handlers
+// that compress responses themselves. Handle and HandleFunc use a writer
+// of their own, which only compresses eligible responses.
 type ResponseWriter struct {
 	io.Writer
 	http.ResponseWriter
@@ -22,6 +71,162 @@ func (w ResponseWriter) Write(b []byte) (int, error) {
 	return w.Writer.Write(b)
 }
 
+// compressWriter is the http.ResponseWriter used by Handle and HandleFunc.
+// It buffers up to MinSize bytes of the response before deciding whether
+// to compress it. Close must be called at the end of the response.
+type compressWriter struct {
+	http.ResponseWriter
+	enc     *encoding
+	level   int            // gzip compression level
+	ew      io.WriteCloser // encoder, if compressing
+	buf     []byte
+	code    int
+	decided bool // whether the response is being compressed is known
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		if w.ew != nil {
+			return w.ew.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= MinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide sends the response headers and the buffered data. The response
+// is compressed if it's eligible and compress is true.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	code := w.code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	if compress && w.enc != nil &&
+		code != http.StatusNoContent &&
+		code != http.StatusNotModified &&
+		code != http.StatusPartialContent &&
+		h.Get("Content-Encoding") == "" &&
+		h.Get("Content-Range") == "" &&
+		compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", w.enc.name)
+		h.Del("Content-Length")
+		w.ew = w.enc.newWriter(w.ResponseWriter, w.level)
+	}
+	w.ResponseWriter.WriteHeader(code)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.ew != nil {
+		_, err = w.ew.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+// Close sends any buffered data and finishes the compressed stream, if any.
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if w.code == 0 && len(w.buf) == 0 {
+			// Nothing was written; let net/http reply as usual.
+			return nil
+		}
+		return w.decide(false)
+	}
+	if w.ew != nil {
+		err := w.ew.Close()
+		w.ew = nil // pooled writers can't be closed twice
+		return err
+	}
+	return nil
+}
+
+// Flush sends any buffered data to the client, compressed if eligible.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if f, ok := w.ew.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, ErrNoHijack
+	}
+	w.decided = true
+	return hj.Hijack()
+}
+
+// Written reports whether the status code or any data was written,
+// including data still buffered.
+func (w *compressWriter) Written() bool {
+	return w.decided || w.code != 0 || len(w.buf) > 0
+}
+
+// Unwrap returns the original ResponseWriter, which is how
+// http.ResponseController finds features like write deadlines.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// addVary adds name to the Vary header, unless it's already there.
+func addVary(h http.Header, name string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimSpace(f)
+			if f == "*" || strings.EqualFold(f, name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
+
+// serve calls fn with a compressWriter if the client supports
+// any of the registered content codings.
+func serve(w http.ResponseWriter, r *http.Request, fn http.HandlerFunc, level int) {
+	addVary(w.Header(), "Accept-Encoding")
+	enc := negotiate(r.Header.Get("Accept-Encoding"))
+	if enc == nil {
+		fn(w, r)
+		return
+	}
+	gw := &compressWriter{ResponseWriter: w, enc: enc, level: level}
+	fn(gw, r)
+	// Not deferred, so a panic in fn doesn't send a partial response.
+	gw.Close()
+}
+
 // Handle provides on-the-fly gzip encoding for other handlers.
 //
 // Usage:
@@ -40,15 +245,19 @@ func (w ResponseWriter) Write(b []byte) (int, error) {
 //		http.ListenAndServe(":8080", autogzip.Handle(http.DefaultServeMux))
 //	}
 func Handle(h http.Handler) http.HandlerFunc {
+	return HandleLevel(h, gzip.DefaultCompression)
+}
+
+// HandleLevel is like Handle, with the given gzip compression level, from
+// gzip.HuffmanOnly to gzip.BestCompression. Servers short on CPU may
+// prefer gzip.BestSpeed, while gzip.DefaultCompression is a balance of
+// speed and size. It panics if the level is not valid.
+func HandleLevel(h http.Handler, level int) http.HandlerFunc {
+	if !validLevel(level) {
+		panic("autogzip: invalid compression level")
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-			h.ServeHTTP(w, r)
-			return
-		}
-		w.Header().Set("Content-Encoding", "gzip")
-		gz := gzip.NewWriter(w)
-		defer gz.Close()
-		h.ServeHTTP(ResponseWriter{Writer: gz, ResponseWriter: w}, r)
+		serve(w, r, h.ServeHTTP, level)
 	}
 }
 
@@ -71,13 +280,6 @@ func Handle(h http.Handler) http.HandlerFunc {
 //	}
 func HandleFunc(fn http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-			fn(w, r)
-			return
-		}
-		w.Header().Set("Content-Encoding", "gzip")
-		gz := gzip.NewWriter(w)
-		defer gz.Close()
-		fn(ResponseWriter{Writer: gz, ResponseWriter: w}, r)
+		serve(w, r, fn, gzip.DefaultCompression)
 	}
 }
@@ -0,0 +1,23 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build brotli
+
+package autogzip
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Brotli is only available when building with the brotli build tag, so
+// the dependency is optional:
+//
+//	go build -tags brotli
+func init() {
+	RegisterEncoding("br", func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	})
+}
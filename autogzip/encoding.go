@@ -0,0 +1,122 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package autogzip
+
+import (
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// encoding is a content coding supported by the server. Compression
+// levels only apply to gzip.
+type encoding struct {
+	name      string
+	newWriter func(w io.Writer, level int) io.WriteCloser
+}
+
+var (
+	encodingsMu sync.RWMutex
+	encodings   = []encoding{{"gzip", newGzipWriter}}
+)
+
+// gzipPools are pools of gzip writers by compression level, from
+// gzip.HuffmanOnly to gzip.BestCompression. Writers are large, and
+// allocating one for each response is costly.
+var gzipPools [gzip.BestCompression - gzip.HuffmanOnly + 1]sync.Pool
+
+// pooledGzip is a gzip.Writer that returns to its pool when closed.
+type pooledGzip struct {
+	*gzip.Writer
+	pool *sync.Pool
+}
+
+func (g *pooledGzip) Close() error {
+	err := g.Writer.Close()
+	g.pool.Put(g)
+	return err
+}
+
+// newGzipWriter returns a gzip writer from the pool of the compression
+// level, which must be valid.
+func newGzipWriter(w io.Writer, level int) io.WriteCloser {
+	pool := &gzipPools[level-gzip.HuffmanOnly]
+	if g, ok := pool.Get().(*pooledGzip); ok {
+		g.Reset(w)
+		return g
+	}
+	gz, _ := gzip.NewWriterLevel(w, level)
+	return &pooledGzip{gz, pool}
+}
+
+// validLevel checks whether level is a valid gzip compression level.
+func validLevel(level int) bool {
+	return level >= gzip.HuffmanOnly && level <= gzip.BestCompression
+}
+
+// RegisterEncoding adds a content coding for compressing responses, like
+// "br" for Brotli, with the function that creates its writers. Writers
+// that implement Flush() error are flushed along with the response.
+//
+// Clients choose codings by their q-values in Accept-Encoding, and among
+// codings they accept equally, those registered last are preferred over
+// gzip and others registered before. Brotli is registered when building
+// with the brotli build tag, see brotli.go.
+func RegisterEncoding(name string, newWriter func(w io.Writer) io.WriteCloser) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	for n, e := range encodings {
+		if e.name == name {
+			encodings[n].newWriter = func(w io.Writer, _ int) io.WriteCloser {
+				return newWriter(w)
+			}
+			return
+		}
+	}
+	encodings = append(encodings, encoding{name,
+		func(w io.Writer, _ int) io.WriteCloser { return newWriter(w) }})
+}
+
+// negotiate returns the content coding preferred by the client in the
+// Accept-Encoding header v, or nil if it doesn't accept any.
+func negotiate(v string) *encoding {
+	if v == "" {
+		return nil
+	}
+	q := make(map[string]float64)
+	for _, part := range strings.Split(v, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		weight := 1.0
+		for _, p := range strings.Split(params, ";") {
+			k, val, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if k == "q" || k == "Q" {
+				if f, err := strconv.ParseFloat(val, 64); err == nil {
+					weight = f
+				}
+			}
+		}
+		q[name] = weight
+	}
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	var (
+		best  *encoding
+		bestQ float64
+	)
+	for n := len(encodings) - 1; n >= 0; n-- {
+		e := &encodings[n]
+		w, ok := q[e.name]
+		if !ok {
+			w, ok = q["*"]
+		}
+		if ok && w > bestQ {
+			best, bestQ = e, w
+		}
+	}
+	return best
+}
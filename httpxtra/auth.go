@@ -0,0 +1,122 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrNoToken is the error reported by BearerAuth for requests without a
+// bearer token.
+var ErrNoToken = errors.New("Missing bearer token")
+
+// BasicAuth returns a middleware that requires HTTP Basic authentication.
+// Credentials are checked by verify, and requests without valid ones are
+// replied with 401 Unauthorized and a WWW-Authenticate header for realm.
+// The authenticated user name is available to handlers via User.
+//
+// Usage:
+//
+//	admin := httpxtra.BasicAuth("admin", httpxtra.StaticCredentials(
+//		map[string]string{"admin": os.Getenv("ADMIN_PASSWORD")}))
+//	mux.Handle("^/admin/", admin(AdminHandler))
+func BasicAuth(realm string, verify func(user, pass string) bool) Middleware {
+	challenge := fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", realm)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			if !ok || !verify(user, pass) {
+				w.Header().Set("WWW-Authenticate", challenge)
+				Error(w, r, http.StatusUnauthorized)
+				return
+			}
+			ctx := context.WithValue(r.Context(), userKey, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// User returns the user name authenticated by BasicAuth, or an empty
+// string.
+func User(r *http.Request) string {
+	u, _ := r.Context().Value(userKey).(string)
+	return u
+}
+
+// StaticCredentials returns a verify function for BasicAuth that accepts
+// the given user names and passwords. Comparisons take constant time, so
+// they don't leak credentials through timing.
+func StaticCredentials(accounts map[string]string) func(user, pass string) bool {
+	type account struct{ user, pass [sha256.Size]byte }
+	l := make([]account, 0, len(accounts))
+	for u, p := range accounts {
+		l = append(l, account{sha256.Sum256([]byte(u)), sha256.Sum256([]byte(p))})
+	}
+	return func(user, pass string) bool {
+		u := sha256.Sum256([]byte(user))
+		p := sha256.Sum256([]byte(pass))
+		ok := 0
+		for _, a := range l {
+			ok |= subtle.ConstantTimeCompare(u[:], a.user[:]) &
+				subtle.ConstantTimeCompare(p[:], a.pass[:])
+		}
+		return ok == 1
+	}
+}
+
+// BearerAuth returns a middleware that requires a bearer token in the
+// Authorization header, like "Authorization: Bearer <token>". Tokens are
+// validated by validate, which may parse JWTs or look them up in a
+// database, and the claims it returns are available to handlers via
+// Claims.
+//
+// Requests without a valid token are replied with 401 Unauthorized, a
+// WWW-Authenticate header and a JSON body like {"error": "..."}, with the
+// message of the error returned by validate. That message is sent to the
+// client, and must not disclose anything sensitive.
+func BearerAuth(validate func(token string) (claims interface{}, err error)) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				WriteJSON(w, r, http.StatusUnauthorized,
+					map[string]string{"error": ErrNoToken.Error()})
+				return
+			}
+			claims, err := validate(token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate",
+					`Bearer error="invalid_token"`)
+				WriteJSON(w, r, http.StatusUnauthorized,
+					map[string]string{"error": err.Error()})
+				return
+			}
+			ctx := context.WithValue(r.Context(), claimsKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// bearerToken returns the bearer token of the Authorization header of r,
+// or an empty string.
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(auth[7:])
+}
+
+// Claims returns the claims of the token validated by BearerAuth, or nil.
+func Claims(r *http.Request) interface{} {
+	return r.Context().Value(claimsKey)
+}
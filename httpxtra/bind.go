@@ -0,0 +1,192 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("Unsupported Content-Type")
+	ErrNotStructPointer     = errors.New("Bind requires a pointer to a struct")
+)
+
+// Bind decodes the request body into v according to its Content-Type:
+//
+//	application/json, application/*+json   JSON, like ReadJSON
+//	application/xml, text/xml, */*+xml      XML
+//	application/x-www-form-urlencoded       form fields
+//	multipart/form-data                     form fields and files
+//
+// Other content types are rejected with ErrUnsupportedMediaType, which
+// handlers usually reply with 415 Unsupported Media Type.
+//
+// Forms are decoded into the exported fields of the struct pointed to by
+// v, named by their "form" tag, or by the field name otherwise. Fields
+// tagged with "-" are skipped. Fields may be strings, booleans or numbers,
+// and *multipart.FileHeader for files. Slices of those get all the values
+// of their field, like those of checkboxes or multiple files, and other
+// fields get the first. URL query parameters are also decoded, with lower
+// precedence than the body.
+//
+// Example:
+//
+//	type Signup struct {
+//		Email  string                `form:"email"`
+//		Age    int                   `form:"age"`
+//		Avatar *multipart.FileHeader `form:"avatar"`
+//		Topics []string              `form:"topics"`
+//	}
+func Bind(r *http.Request, v interface{}) error {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case isJSON(ct):
+		return ReadJSON(r, v, nil)
+	case ct == "application/xml" || ct == "text/xml" ||
+		strings.HasSuffix(ct, "+xml"):
+		return readXML(r, v)
+	case ct == "application/x-www-form-urlencoded" ||
+		ct == "multipart/form-data":
+		if err := parseMultipartForm(r); err != nil {
+			return err
+		}
+		var files map[string][]*multipart.FileHeader
+		if r.MultipartForm != nil {
+			files = r.MultipartForm.File
+		}
+		return bindForm(r.Form, files, v)
+	default:
+		return ErrUnsupportedMediaType
+	}
+}
+
+// readXML reads the request body and decodes its XML content into v.
+func readXML(r *http.Request, v interface{}) error {
+	b, err := readBody(r, 0)
+	if err != nil {
+		return err
+	}
+	if err = xml.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("Malformed XML: %v", err)
+	}
+	return nil
+}
+
+// bindForm decodes the form values and files into the struct pointed to
+// by v.
+func bindForm(form url.Values, files map[string][]*multipart.FileHeader, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrNotStructPointer
+	}
+	return bindStruct(form, files, rv.Elem())
+}
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+func bindStruct(form url.Values, files map[string][]*multipart.FileHeader, sv reflect.Value) error {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		fv := sv.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := bindStruct(form, files, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if sf.PkgPath != "" || !fv.CanSet() {
+			continue // unexported
+		}
+		name := sf.Tag.Get("form")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		switch sf.Type {
+		case fileHeaderType:
+			if fh := files[name]; len(fh) > 0 {
+				fv.Set(reflect.ValueOf(fh[0]))
+			}
+			continue
+		case fileHeadersType:
+			if fh := files[name]; len(fh) > 0 {
+				fv.Set(reflect.ValueOf(fh))
+			}
+			continue
+		}
+		vs, ok := form[name]
+		if !ok || len(vs) == 0 {
+			continue
+		}
+		if sf.Type.Kind() != reflect.Slice {
+			if err := setValue(fv, vs[0]); err != nil {
+				return fmt.Errorf("Invalid value for %q: %v", name, err)
+			}
+			continue
+		}
+		sl := reflect.MakeSlice(sf.Type, len(vs), len(vs))
+		for n, s := range vs {
+			if err := setValue(sl.Index(n), s); err != nil {
+				return fmt.Errorf("Invalid value %d for %q: %v", n, name, err)
+			}
+		}
+		fv.Set(sl)
+	}
+	return nil
+}
+
+// setValue sets v to the value of s, converted to the kind of v.
+func setValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		if s == "" || s == "on" {
+			v.SetBool(s == "on") // HTML checkboxes
+			return nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
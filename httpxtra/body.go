@@ -0,0 +1,108 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// MaxBodyBytes returns a middleware that limits the size of request bodies
+// to n bytes, overriding the MaxBodyBytes of the Handler serving the
+// request. It's meant for routes that legitimately accept large uploads,
+// or that must be stricter than the rest of the server.
+//
+// Requests with a larger Content-Length are rejected with 413 Request
+// Entity Too Large. Other requests get their body wrapped with
+// http.MaxBytesReader, so reads beyond the limit fail. When served by a
+// Handler, requests whose handler hit the limit without writing a
+// response are replied with 413 as well.
+func MaxBodyBytes(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, ok := limitBody(w, r, n)
+			if !ok {
+				Error(w, r, http.StatusRequestEntityTooLarge)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitBody returns a shallow copy of r with its original body limited to
+// n bytes. It returns false if the Content-Length of r is larger than n.
+func limitBody(w http.ResponseWriter, r *http.Request, n int64) (*http.Request, bool) {
+	if r.ContentLength > n {
+		return r, false
+	}
+	s := getState(r)
+	body := r.Body
+	if s != nil {
+		if s.body == nil {
+			s.body = body
+		} else {
+			body = s.body
+		}
+	}
+	if body == nil || body == http.NoBody {
+		return r, true
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, body, n), s: s}
+	return r2, true
+}
+
+// limitedBody records in the request state when reads from the wrapped
+// http.MaxBytesReader exceed its limit.
+type limitedBody struct {
+	io.ReadCloser
+	s *state
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var e *http.MaxBytesError
+	if err != nil && b.s != nil && errors.As(err, &e) {
+		b.s.tooLarge = true
+	}
+	return n, err
+}
+
+// RawBody reads and returns the whole request body, and replaces r.Body
+// with a reader of the same bytes, so handlers and helpers like ReadJSON
+// and Bind can still read it afterwards. It's meant for middleware that
+// needs the exact bytes sent by the client, like VerifySignature.
+//
+// For requests served by a Handler, the body is cached, and later calls
+// return the same bytes, also for copies of the request made by
+// middleware. Bodies are limited by MaxBodyBytes, of the Handler or the
+// middleware, and reading larger ones returns ErrBodyTooLarge. Requests
+// with no body return nil.
+func RawBody(r *http.Request) ([]byte, error) {
+	s := getState(r)
+	if s != nil && s.rawRead {
+		r.Body = io.NopCloser(bytes.NewReader(s.raw))
+		return s.raw, nil
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(r.Body)
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return nil, ErrBodyTooLarge
+	} else if err != nil {
+		return nil, err
+	}
+	if s != nil {
+		s.raw, s.rawRead = b, true
+	}
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
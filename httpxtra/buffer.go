@@ -0,0 +1,128 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// Flush sends any buffered response data to the client, if w supports
+// flushing, directly or through its Unwrap method, and does nothing
+// otherwise. Responses buffered for Content-Length, see Handler.BufferSize,
+// are sent as they're written from the first flush on.
+func Flush(w http.ResponseWriter) {
+	http.NewResponseController(w).Flush()
+}
+
+// bufferWriter buffers responses up to max bytes, so complete responses
+// are sent with Content-Length and a sniffed Content-Type. Larger
+// responses are streamed once they exceed max.
+type bufferWriter struct {
+	w    http.ResponseWriter
+	max  int
+	buf  []byte
+	code int
+	sent bool // whether the buffer was sent, and writes pass through
+}
+
+func (bw *bufferWriter) Header() http.Header {
+	return bw.w.Header()
+}
+
+func (bw *bufferWriter) WriteHeader(code int) {
+	if bw.sent || code < 200 && code != http.StatusSwitchingProtocols {
+		bw.w.WriteHeader(code)
+		return
+	}
+	if bw.code == 0 {
+		bw.code = code
+	}
+}
+
+func (bw *bufferWriter) Write(b []byte) (int, error) {
+	if bw.sent {
+		return bw.w.Write(b)
+	}
+	bw.buf = append(bw.buf, b...)
+	if len(bw.buf) > bw.max {
+		if err := bw.send(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// send sends the status code and the buffered data, if any.
+func (bw *bufferWriter) send() error {
+	if bw.sent {
+		return nil
+	}
+	bw.sent = true
+	if bw.code == 0 && len(bw.buf) == 0 {
+		return nil
+	}
+	h := bw.w.Header()
+	if h.Get("Content-Type") == "" && len(bw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(bw.buf))
+	}
+	if bw.code != 0 {
+		bw.w.WriteHeader(bw.code)
+	}
+	if len(bw.buf) == 0 {
+		return nil
+	}
+	_, err := bw.w.Write(bw.buf)
+	bw.buf = nil
+	return err
+}
+
+// finish sends the complete response, with its Content-Length if the
+// handler didn't set one and the status allows a body.
+func (bw *bufferWriter) finish() {
+	if bw.sent {
+		return
+	}
+	h := bw.w.Header()
+	if h.Get("Content-Length") == "" && h.Get("Transfer-Encoding") == "" &&
+		bw.code != http.StatusNoContent && bw.code != http.StatusNotModified &&
+		(bw.code != 0 || len(bw.buf) > 0) {
+		h.Set("Content-Length", strconv.Itoa(len(bw.buf)))
+	}
+	bw.send()
+}
+
+func (bw *bufferWriter) Flush() {
+	bw.send()
+	if f, ok := bw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (bw *bufferWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := bw.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, ErrNoHijack
+	}
+	conn, buf, err := hj.Hijack()
+	if err == nil {
+		bw.sent = true
+		bw.buf = nil
+	}
+	return conn, buf, err
+}
+
+func (bw *bufferWriter) Written() bool {
+	if bw.sent {
+		return written(bw.w)
+	}
+	return bw.code != 0 || len(bw.buf) > 0
+}
+
+func (bw *bufferWriter) Unwrap() http.ResponseWriter {
+	return bw.w
+}
@@ -0,0 +1,92 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+type contextKey int
+
+const (
+	stateKey contextKey = iota
+	userKey
+	claimsKey
+	sessionKey
+)
+
+// state is the per-request state kept by Handler in the request context.
+type state struct {
+	h         *Handler
+	query     url.Values // parsed URL query, see Query
+	requestID string     // see RequestID
+
+	body     io.ReadCloser // original request body, see MaxBodyBytes
+	tooLarge bool          // whether reading the body exceeded the limit
+	raw      []byte        // cached request body, see RawBody
+	rawRead  bool          // whether raw is set
+
+	mu     sync.Mutex
+	values map[interface{}]interface{} // see Set
+}
+
+// newState attaches the state s for h to the request context.
+func newState(r *http.Request, h *Handler, s *state) (*http.Request, *state) {
+	s.h = h
+	return r.WithContext(context.WithValue(r.Context(), stateKey, s)), s
+}
+
+// getState returns the state of a request served by Handler, or nil.
+func getState(r *http.Request) *state {
+	s, _ := r.Context().Value(stateKey).(*state)
+	return s
+}
+
+// settings returns the Handler serving r. Requests that are not served by
+// a Handler get the zero value, with default settings.
+func settings(r *http.Request) *Handler {
+	if s := getState(r); s != nil {
+		return s.h
+	}
+	return &Handler{}
+}
+
+// Set stores a value for the request under key, for handlers and
+// middleware down the chain to read with Get. Values are kept in a map of
+// the request served by Handler, rather than in a chain of contexts, so
+// they don't need copies of the request. Set panics if r is not served by
+// a Handler.
+//
+// Like context keys, keys should be of unexported types defined by each
+// package, to avoid collisions. Values stored by httpxtra have their own
+// accessors: RequestID, ClientIP, User, Claims and GetSession, and those
+// of routing are available with remux.Vars, Params, Route and RouteMeta.
+func Set(r *http.Request, key, value interface{}) {
+	s := getState(r)
+	if s == nil {
+		panic("httpxtra: Set called for a request not served by Handler")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.values == nil {
+		s.values = make(map[interface{}]interface{})
+	}
+	s.values[key] = value
+}
+
+// Get returns the value stored for the request under key with Set, or nil.
+func Get(r *http.Request, key interface{}) interface{} {
+	s := getState(r)
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
@@ -0,0 +1,126 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrNoSecret         = errors.New("Cookie secret is not set")
+	ErrInvalidSignature = errors.New("Invalid cookie signature")
+)
+
+// cookieSecret returns the CookieSecret of the Handler serving r.
+func cookieSecret(r *http.Request) ([]byte, error) {
+	secret := settings(r).CookieSecret
+	if len(secret) == 0 {
+		return nil, ErrNoSecret
+	}
+	return secret, nil
+}
+
+// SetSignedCookie sets the cookie c with its value signed with
+// HMAC-SHA256, using the CookieSecret of the Handler serving the request.
+// Clients can read the value, but can't modify it. See SignedCookie.
+func SetSignedCookie(w http.ResponseWriter, r *http.Request, c *http.Cookie) error {
+	secret, err := cookieSecret(r)
+	if err != nil {
+		return err
+	}
+	sc := *c
+	sc.Value = signValue(secret, c.Name,
+		base64.RawURLEncoding.EncodeToString([]byte(c.Value)))
+	http.SetCookie(w, &sc)
+	return nil
+}
+
+// SignedCookie returns the value of the named cookie set by
+// SetSignedCookie. It returns http.ErrNoCookie if the cookie is not
+// present, or ErrInvalidSignature if it was tampered with.
+func SignedCookie(r *http.Request, name string) (string, error) {
+	secret, err := cookieSecret(r)
+	if err != nil {
+		return "", err
+	}
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	v, ok := verifyValue(secret, name, c.Value)
+	if !ok {
+		return "", ErrInvalidSignature
+	}
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	return string(b), nil
+}
+
+// SetEncryptedCookie sets the cookie c with its value encrypted with
+// AES-GCM, using a key derived from the CookieSecret of the Handler
+// serving the request. Clients can neither read nor modify the value.
+// See EncryptedCookie.
+func SetEncryptedCookie(w http.ResponseWriter, r *http.Request, c *http.Cookie) error {
+	aead, err := cookieCipher(r)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(c.Value)+aead.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+	sc := *c
+	sc.Value = base64.RawURLEncoding.EncodeToString(
+		aead.Seal(nonce, nonce, []byte(c.Value), []byte(c.Name)))
+	http.SetCookie(w, &sc)
+	return nil
+}
+
+// EncryptedCookie returns the value of the named cookie set by
+// SetEncryptedCookie. It returns http.ErrNoCookie if the cookie is not
+// present, or ErrInvalidSignature if it can't be decrypted.
+func EncryptedCookie(r *http.Request, name string) (string, error) {
+	aead, err := cookieCipher(r)
+	if err != nil {
+		return "", err
+	}
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil || len(b) < aead.NonceSize() {
+		return "", ErrInvalidSignature
+	}
+	n := aead.NonceSize()
+	v, err := aead.Open(nil, b[:n], b[n:], []byte(name))
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	return string(v), nil
+}
+
+// cookieCipher returns the AES-GCM cipher for encrypted cookies. Its key
+// is derived from the cookie secret, so it differs from the signing key.
+func cookieCipher(r *http.Request) (cipher.AEAD, error) {
+	secret, err := cookieSecret(r)
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256(append([]byte("httpxtra encrypted cookie\x00"), secret...))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
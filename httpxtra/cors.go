@@ -0,0 +1,148 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSOptions configures the CORS middleware.
+type CORSOptions struct {
+	// AllowedOrigins is the list of origins allowed to make cross-origin
+	// requests, like "https://example.com". An origin may contain a
+	// wildcard for subdomains, like "https://*.example.com", and "*"
+	// allows any origin.
+	AllowedOrigins []string
+
+	// AllowedMethods are the methods allowed in preflight requests.
+	// Defaults to GET, HEAD and POST.
+	AllowedMethods []string
+
+	// AllowedHeaders are the request headers allowed in preflight
+	// requests.
+	AllowedHeaders []string
+
+	// ExposedHeaders are the response headers exposed to the client.
+	ExposedHeaders []string
+
+	// MaxAge is how long, in seconds, preflight results can be cached.
+	MaxAge int
+
+	// AllowCredentials allows requests with cookies or HTTP auth.
+	// Because "*" can't be used with credentials, the origin of the
+	// request is reflected instead.
+	AllowCredentials bool
+
+	// Match, if set, restricts preflight requests to those for routes
+	// that accept the requested method, like remux.ServeMux.Match. It's
+	// called with a copy of the request with the method set to that of
+	// Access-Control-Request-Method. Preflight requests that don't match
+	// are passed to the next handler, which usually replies with 404 or
+	// 405, and the browser fails the actual request.
+	Match func(r *http.Request) bool
+}
+
+// allowOrigin returns the value of Access-Control-Allow-Origin for the
+// given origin, or an empty string if the origin is not allowed.
+func (o *CORSOptions) allowOrigin(origin string) string {
+	for _, v := range o.AllowedOrigins {
+		if v == "*" {
+			if o.AllowCredentials {
+				return origin
+			}
+			return "*"
+		}
+		if v == origin {
+			return origin
+		}
+		if n := strings.Index(v, "*."); n >= 0 &&
+			strings.HasPrefix(origin, v[:n]) &&
+			strings.HasSuffix(origin, v[n+1:]) &&
+			len(origin) > len(v)-1 {
+			return origin
+		}
+	}
+	return ""
+}
+
+// CORS returns a middleware that implements Cross-Origin Resource Sharing.
+//
+// Origins are only reflected in Access-Control-Allow-Origin when they're
+// in AllowedOrigins. Preflight requests, which are OPTIONS requests with
+// the Access-Control-Request-Method header, are answered with 204 No
+// Content without calling the next handler.
+//
+// Routes don't need OPTIONS handlers for preflight requests when CORS
+// wraps the router, for example in Handler.Middleware, since preflight
+// requests are answered before routing. With Match, they're answered only
+// for routes that accept the requested method. When CORS is middleware of
+// routes instead, like in a remux.Group, preflight requests to routes
+// restricted to other methods never reach it, and get 405 Method Not
+// Allowed, or the Allow header without CORS headers with AutoOptions.
+//
+// Usage:
+//
+//	cors := httpxtra.CORS(httpxtra.CORSOptions{
+//		AllowedOrigins:   []string{"https://*.example.com"},
+//		AllowedMethods:   []string{"GET", "POST", "PUT"},
+//		AllowCredentials: true,
+//		Match:            mux.Match,
+//	})
+//	h := httpxtra.Handler{Handler: mux, Middleware: []httpxtra.Middleware{cors}}
+func CORS(opts CORSOptions) Middleware {
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{"GET", "HEAD", "POST"}
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(opts.AllowedHeaders, ", ")
+	exposeHeaders := strings.Join(opts.ExposedHeaders, ", ")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			method := r.Header.Get("Access-Control-Request-Method")
+			preflight := r.Method == "OPTIONS" && method != ""
+			if preflight && opts.Match != nil {
+				r2 := new(http.Request)
+				*r2 = *r
+				r2.Method = method
+				preflight = opts.Match(r2)
+			}
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			AddVary(w, "Origin")
+			allow := opts.allowOrigin(origin)
+			if allow != "" {
+				h.Set("Access-Control-Allow-Origin", allow)
+				if opts.AllowCredentials {
+					h.Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+			if !preflight {
+				if allow != "" && exposeHeaders != "" {
+					h.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if allow != "" {
+				h.Set("Access-Control-Allow-Methods", allowMethods)
+				if allowHeaders != "" {
+					h.Set("Access-Control-Allow-Headers", allowHeaders)
+				}
+				if opts.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age",
+						strconv.Itoa(opts.MaxAge))
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"encoding/csv"
+	"mime"
+	"net/http"
+)
+
+// CSVWriter prepares the response for CSV content, and returns a
+// csv.Writer that streams rows to the client. The response is sent as an
+// attachment with the given file name, unless it's empty.
+//
+// Rows are buffered by the csv.Writer, and flushed to the client each time
+// its buffer fills, so large exports don't need to fit in memory. The
+// caller must call Flush after the last row, and check Error.
+//
+// Usage:
+//
+//	func ExportHandler(w http.ResponseWriter, r *http.Request) {
+//		cw := httpxtra.CSVWriter(w, "export.csv")
+//		for _, row := range rows {
+//			if err := cw.Write(row); err != nil {
+//				return // client is gone
+//			}
+//		}
+//		cw.Flush()
+//	}
+func CSVWriter(w http.ResponseWriter, filename string) *csv.Writer {
+	h := w.Header()
+	h.Set("Content-Type", "text/csv; charset=utf-8")
+	if filename != "" {
+		h.Set("Content-Disposition", mime.FormatMediaType("attachment",
+			map[string]string{"filename": filename}))
+	}
+	if f, ok := w.(http.Flusher); ok {
+		return csv.NewWriter(flushWriter{w, f})
+	}
+	return csv.NewWriter(w)
+}
+
+// flushWriter flushes each write to the client.
+type flushWriter struct {
+	w http.ResponseWriter
+	f http.Flusher
+}
+
+func (fw flushWriter) Write(b []byte) (int, error) {
+	n, err := fw.w.Write(b)
+	if err == nil {
+		fw.f.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,57 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"log"
+	"net/http"
+)
+
+// Error replies to the request with the given HTTP status code.
+//
+// If the request is served by a Handler with an ErrorHandlers entry for
+// the code, that handler is called to render the response, and must set
+// the status code itself. Otherwise, clients that prefer JSON in their
+// Accept header get a JSON object like {"error": "Not Found"}, and all
+// others get the status text in plain text, like http.Error.
+//
+// If the status code or part of the response was already written, which
+// can't be undone, a warning is logged and nothing else is written. This
+// is detected through the writers of Handler and autogzip.
+func Error(w http.ResponseWriter, r *http.Request, code int) {
+	if written(w) {
+		log.Printf("httpxtra: response already started, "+
+			"can't reply to %s %s with %d", r.Method, r.URL, code)
+		return
+	}
+	if h := settings(r).ErrorHandlers[code]; h != nil {
+		h.ServeHTTP(w, r)
+		return
+	}
+	text := http.StatusText(code)
+	AddVary(w, "Accept")
+	if Negotiate(r, "text/plain", "application/json") == "application/json" {
+		err := WriteJSON(w, r, code, map[string]string{"error": text})
+		if err == nil {
+			return
+		}
+	}
+	http.Error(w, text, code)
+}
+
+// written checks whether the response status or data was written to w,
+// or to any writer it wraps.
+func written(w http.ResponseWriter) bool {
+	for {
+		switch v := w.(type) {
+		case interface{ Written() bool }:
+			return v.Written()
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return false
+		}
+	}
+}
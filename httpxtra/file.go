@@ -0,0 +1,201 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrUnsafePath is returned by SafeJoin for paths that escape the root.
+var ErrUnsafePath = errors.New("Unsafe path")
+
+// SafeJoin joins root and the user provided path p, making sure that the
+// result is within root. The path is URL-decoded and cleaned first, which
+// catches encoded "%2e%2e" elements. Absolute paths are relative to root.
+// It returns ErrUnsafePath for any attempt to escape root.
+func SafeJoin(root, p string) (string, error) {
+	p, err := url.PathUnescape(p)
+	if err != nil || strings.IndexByte(p, 0) >= 0 {
+		return "", ErrUnsafePath
+	}
+	fn := filepath.Join(root, filepath.FromSlash(p))
+	rel, err := filepath.Rel(root, fn)
+	if err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrUnsafePath
+	}
+	return fn, nil
+}
+
+// ServeDir replies to the request with the contents of the file or
+// directory name, relative to root. Names that escape root are rejected
+// with 400 Bad Request, see SafeJoin.
+//
+// Files are sent with Last-Modified and weak ETag headers, and conditional
+// requests with If-None-Match or If-Modified-Since are replied with 304 Not
+// Modified when the file hasn't changed. Range requests are supported too.
+// The Cache-Control header is set to the StaticCacheControl of the Handler
+// serving the request, if any. Errors are sent as in ServeFile.
+//
+// Directories are served by their index.html file. Directories without
+// index.html are listed if the request is served by a Handler with
+// DirListing set, or not found otherwise.
+//
+// Usage:
+//
+//	func StaticHandler(w http.ResponseWriter, r *http.Request) {
+//		httpxtra.ServeDir(w, r, "./static", remux.Vars(r)[0])
+//	}
+//
+//	func main() {
+//		remux.HandleFunc("^/static/(.*)$", StaticHandler)
+//		...
+//	}
+func ServeDir(w http.ResponseWriter, r *http.Request, root, name string) {
+	fn, err := SafeJoin(root, name)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest)
+		return
+	}
+	fi, err := os.Stat(fn)
+	if err != nil {
+		fileError(w, r, err)
+		return
+	}
+	if fi.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			// Relative, so it works under the prefix of remux.Mount or
+			// http.StripPrefix too. http.Redirect would make it absolute.
+			u := path.Base(r.URL.Path) + "/"
+			if r.URL.RawQuery != "" {
+				u += "?" + r.URL.RawQuery
+			}
+			w.Header().Set("Location", u)
+			w.WriteHeader(http.StatusMovedPermanently)
+			return
+		}
+		index := filepath.Join(fn, "index.html")
+		if ifi, err := os.Stat(index); err == nil {
+			fn, fi = index, ifi
+		} else if settings(r).DirListing {
+			http.ServeFile(w, r, fn)
+			return
+		} else {
+			Error(w, r, http.StatusNotFound)
+			return
+		}
+	}
+	serveFile(w, r, fn, fi)
+}
+
+// ServeFile replies to the request with the contents of the named file,
+// with the same headers and conditional requests as ServeDir. The name
+// is not checked, and must not come from the request, see ServeDir.
+//
+// Files that don't exist, and directories, are replied with 404 Not Found,
+// files that can't be read for lack of permissions with 403 Forbidden,
+// and other errors with 500 Internal Server Error. Responses are sent by
+// Error, so ErrorHandlers apply, and don't include the file name.
+func ServeFile(w http.ResponseWriter, r *http.Request, name string) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		fileError(w, r, err)
+		return
+	}
+	if fi.IsDir() {
+		Error(w, r, http.StatusNotFound)
+		return
+	}
+	serveFile(w, r, name, fi)
+}
+
+// serveFile serves the regular file fn with FileInfo fi.
+func serveFile(w http.ResponseWriter, r *http.Request, fn string, fi os.FileInfo) {
+	f, err := os.Open(fn)
+	if err != nil {
+		fileError(w, r, err)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("ETag", FileETag(fi))
+	if cc := settings(r).StaticCacheControl; cc != "" {
+		w.Header().Set("Cache-Control", cc)
+	}
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+}
+
+// fileError replies to the request with the status code for the file
+// system error err. Unexpected errors are logged.
+func fileError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, syscall.ENOTDIR):
+		Error(w, r, http.StatusNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		Error(w, r, http.StatusForbidden)
+	default:
+		log.Printf("httpxtra: serving %s %s: %v", r.Method, r.URL, err)
+		Error(w, r, http.StatusInternalServerError)
+	}
+}
+
+// FileETag returns a weak ETag for the file, based on its size and
+// modification time.
+func FileETag(fi os.FileInfo) string {
+	return fmt.Sprintf(`W/"%x-%x"`, fi.Size(), fi.ModTime().UnixNano())
+}
+
+// ServeContent replies to the request with the given content, like
+// http.ServeContent, for generated content like exports that benefits
+// from range requests and conditional requests. The Content-Type is taken
+// from the extension of name, or sniffed.
+//
+// Unless the response already has an ETag, a weak one is set based on the
+// size of the content and modtime, like FileETag, so clients get 304 Not
+// Modified for content they have. A zero modtime sets no ETag, nor
+// Last-Modified.
+func ServeContent(w http.ResponseWriter, r *http.Request, name string,
+	modtime time.Time, content io.ReadSeeker) {
+	if !modtime.IsZero() && w.Header().Get("ETag") == "" {
+		size, err := content.Seek(0, io.SeekEnd)
+		if err == nil {
+			_, err = content.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			Error(w, r, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("ETag", fmt.Sprintf(`W/"%x-%x"`, size, modtime.UnixNano()))
+	}
+	http.ServeContent(w, r, name, modtime, content)
+}
+
+// SetCacheControl sets the Cache-Control header of the response, allowing
+// clients to cache it for maxAge. Public responses may be cached by shared
+// caches, like proxies and CDNs, and private ones by browsers only. A zero
+// maxAge makes clients validate the response on every use.
+func SetCacheControl(w http.ResponseWriter, maxAge time.Duration, public bool) {
+	scope := "private"
+	if public {
+		scope = "public"
+	}
+	if maxAge <= 0 {
+		w.Header().Set("Cache-Control", scope+", no-cache")
+		return
+	}
+	w.Header().Set("Cache-Control",
+		fmt.Sprintf("%s, max-age=%d", scope, int64(maxAge/time.Second)))
+}
@@ -0,0 +1,87 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// DefaultMaxMultipartMemory is the default maximum memory used for parsing
+// multipart forms. The rest of the form is stored in temporary files.
+const DefaultMaxMultipartMemory = 32 << 20
+
+// parseMultipartForm parses the form of the request with the memory limit
+// of the Handler serving it. Requests that are not multipart get their
+// URL query and urlencoded body parsed.
+func parseMultipartForm(r *http.Request) error {
+	if r.MultipartForm != nil {
+		return nil
+	}
+	max := settings(r).MaxMultipartMemory
+	if max <= 0 {
+		max = DefaultMaxMultipartMemory
+	}
+	err := r.ParseMultipartForm(max)
+	if err == http.ErrNotMultipart {
+		return nil
+	}
+	return err
+}
+
+// FormValue returns the first value of the named form field, from either
+// the URL query, or urlencoded or multipart request bodies.
+func FormValue(r *http.Request, name string) string {
+	parseMultipartForm(r)
+	return r.FormValue(name)
+}
+
+// FormValues returns all the values of the named form field, like those
+// of checkboxes, from both the URL query and the request body.
+func FormValues(r *http.Request, name string) []string {
+	parseMultipartForm(r)
+	return r.Form[name]
+}
+
+// FormFile returns the first file of the named field of a multipart form.
+// Forms are parsed with the MaxMultipartMemory of the Handler serving the
+// request.
+func FormFile(r *http.Request, name string) (multipart.File, *multipart.FileHeader, error) {
+	if err := parseMultipartForm(r); err != nil {
+		return nil, nil, err
+	}
+	return r.FormFile(name)
+}
+
+// SaveUploadedFile saves the uploaded file fh to dst. The file is written
+// to a temporary file in the same directory first, and renamed to dst when
+// complete, so dst is never left partially written.
+func SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	f, err := os.CreateTemp(filepath.Dir(dst), ".upload-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op after rename
+	if _, err = io.Copy(f, src); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), dst)
+}
@@ -4,40 +4,246 @@
 
 // httpxtra is a wrapper for http.Handler that adds extra features to the server:
 // - Custom logging
+// - Recovery of panics in handlers
 // - Support for listening on TCP or UNIX sockets
 // - Support X-Real-IP and X-Forwarded-For as the remote IP if the server sits
 //   behind a proxy or load balancer.
 package httpxtra
 
 import (
+	"compress/gzip"
+	"log"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"runtime/debug"
 	"time"
+
+	"github.com/fiorix/go-web/autogzip"
+	"github.com/fiorix/go-web/remux"
 )
 
 // Handler is the http.Handler wrapper with extra features.
+//
+// The http.ResponseWriter passed to handlers wraps the one of the server.
+// It implements http.Flusher and http.Hijacker; Hijack returns ErrNoHijack
+// when the underlying writer can't be hijacked. Its Unwrap method returns
+// the wrapped writer, so http.NewResponseController reaches features like
+// write deadlines.
 type Handler struct {
 	Handler  http.Handler
 	Logger   LoggerFunc
 	XHeaders bool
+
+	// LogSample makes Logger log a random sample of 1 in LogSample
+	// requests, for busy servers. Server errors, with status 500 or
+	// above, and requests slower than SlowRequest are always logged.
+	// Values below 2 log all requests.
+	LogSample int
+
+	// SlowRequest is the duration above which requests are always
+	// logged when sampling with LogSample.
+	SlowRequest time.Duration
+
+	// TrustedProxies restricts XHeaders to requests coming from these
+	// networks. When set, X-Forwarded-For is walked from right to left
+	// and the first address that is not a trusted proxy is the client,
+	// while X-Real-IP is ignored, since clients can set it. When empty,
+	// XHeaders are always honored.
+	TrustedProxies []net.IPNet
+
+	// AllowedHosts restricts the Host header of requests to these host
+	// names, like "example.com" or "*.example.com" for any subdomain.
+	// Other requests are rejected with 400 Bad Request, which prevents
+	// host header attacks like cache poisoning with forged links. When
+	// empty, all hosts are allowed.
+	AllowedHosts []string
+
+	// ServerName is the Server header of responses, like "example/1.0".
+	// No Server header is sent when empty, which is the net/http default,
+	// and avoids revealing software versions.
+	ServerName string
+
+	// RequestID enables request IDs, available to handlers and loggers
+	// via the RequestID function, and sent to the client in the
+	// X-Request-ID header. IDs are taken from the X-Request-ID header of
+	// requests, like those sent by proxies, or generated otherwise.
+	RequestID bool
+
+	// Debug enables stack traces in the log when handlers panic.
+	Debug bool
+
+	// NoRecover disables the recovery of panics in handlers. By default,
+	// panics are logged and turned into 500 Internal Server Error.
+	NoRecover bool
+
+	// PanicHandler, if set, is called with the value of recovered panics,
+	// before the error response is sent. Useful for reporting.
+	PanicHandler func(r *http.Request, v interface{})
+
+	// Gzip enables on-the-fly gzip encoding of responses, see autogzip.
+	Gzip bool
+
+	// GzipLevel is the gzip compression level, from gzip.HuffmanOnly to
+	// gzip.BestCompression, see autogzip.HandleLevel. Zero means
+	// gzip.DefaultCompression.
+	GzipLevel int
+
+	// Middleware is applied to every request, outermost first.
+	Middleware []Middleware
+
+	// DirListing enables listing the contents of directories without
+	// index.html in ServeDir.
+	DirListing bool
+
+	// CookieSecret is the key for signed and encrypted cookies, and the
+	// default for sessions. It must be long and random, and kept private.
+	CookieSecret []byte
+
+	// StaticCacheControl is the Cache-Control header of files served by
+	// ServeDir, like "public, max-age=86400". Fingerprinted assets that
+	// never change may use "public, max-age=31536000, immutable".
+	StaticCacheControl string
+
+	// ErrorHandlers are custom handlers for error responses sent by
+	// Error, by status code. They must write the status code.
+	ErrorHandlers map[int]http.Handler
+
+	// BufferSize enables buffering responses of up to this many bytes,
+	// so they're sent with Content-Length, and with a Content-Type sniffed
+	// with http.DetectContentType if not set. Larger responses are sent as
+	// they're written. Without it, net/http does the same for responses
+	// of up to about 2KB written before the handler returns.
+	BufferSize int
+
+	// MaxBodyBytes is the maximum size of request bodies. Requests with a
+	// larger Content-Length are rejected with 413 Request Entity Too Large,
+	// and reading beyond the limit fails. Handlers that hit the limit and
+	// don't write a response get a 413 too. Zero means no limit. See the
+	// MaxBodyBytes middleware for per-route limits.
+	MaxBodyBytes int64
+
+	// MaxMultipartMemory is the maximum memory used by FormValue and
+	// FormFile for parsing multipart forms. Defaults to
+	// DefaultMaxMultipartMemory.
+	MaxMultipartMemory int64
+
+	// PrettyJSON makes WriteJSON emit indented JSON.
+	PrettyJSON bool
+
+	// StrictJSON makes ReadJSON and Bind reject JSON objects with unknown
+	// fields, see JSONOptions.
+	StrictJSON bool
 }
 
-func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+// serving holds the settings, writer and state of a request, which all
+// outlive ServeHTTP, so they're allocated at once. They're not pooled,
+// because handlers may keep the request or the writer after returning,
+// for example in goroutines left behind by the Timeout middleware.
+type serving struct {
+	h  Handler
+	lw logWriter
+	s  state
+}
+
+func (hv Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	t := time.Now()
-	lw := logWriter{w: w}
+	sv := &serving{h: hv, lw: logWriter{w: w}}
+	h, lw := &sv.h, &sv.lw
 	if h.Handler == nil {
 		h.Handler = http.DefaultServeMux
 	}
+	r, s := newState(remux.Track(r), h, &sv.s)
+	if h.ServerName != "" {
+		lw.Header().Set("Server", h.ServerName)
+	}
+	if h.RequestID {
+		s.requestID = requestID(r)
+		lw.Header().Set(RequestIDHeader, s.requestID)
+	}
 	if h.XHeaders {
-		ip := r.Header.Get("X-Real-IP")
-		if ip == "" {
-			ip = r.Header.Get("X-Forwarded-For")
-		}
-		if ip != "" {
+		if ip := h.forwardedIP(r); ip != "" {
 			r.RemoteAddr = ip
 		}
 	}
-	h.Handler.ServeHTTP(&lw, r)
+	func() {
+		if !h.NoRecover {
+			defer h.recoverPanic(lw, r)
+		}
+		if len(h.AllowedHosts) > 0 && !hostAllowed(r.Host, h.AllowedHosts) {
+			Error(lw, r, http.StatusBadRequest)
+			return
+		}
+		if h.MaxBodyBytes > 0 {
+			var ok bool
+			if r, ok = limitBody(lw, r, h.MaxBodyBytes); !ok {
+				Error(lw, r, http.StatusRequestEntityTooLarge)
+				return
+			}
+		}
+		next := Chain(h.Handler, h.Middleware...)
+		if h.Gzip {
+			level := h.GzipLevel
+			if level == 0 {
+				level = gzip.DefaultCompression
+			}
+			next = autogzip.HandleLevel(next, level)
+		}
+		if h.BufferSize > 0 {
+			bw := &bufferWriter{w: lw, max: h.BufferSize}
+			next.ServeHTTP(bw, r)
+			bw.finish()
+		} else {
+			next.ServeHTTP(lw, r)
+		}
+		if s.tooLarge && lw.status == 0 && !lw.hijacked {
+			Error(lw, r, http.StatusRequestEntityTooLarge)
+		}
+	}()
 	if h.Logger != nil {
-		h.Logger(r, t, lw.status, lw.bytes)
+		if lw.status == 0 {
+			// Nothing written, net/http replies with 200.
+			lw.status = http.StatusOK
+		}
+		if h.sampled(lw.status, time.Since(t)) {
+			h.Logger(r, t, lw.status, lw.bytes)
+		}
+	}
+}
+
+// sampled checks whether a request with the given status and duration is
+// logged, see LogSample.
+func (h *Handler) sampled(status int, d time.Duration) bool {
+	switch {
+	case h.LogSample < 2 || status >= 500:
+		return true
+	case h.SlowRequest > 0 && d > h.SlowRequest:
+		return true
+	}
+	return rand.IntN(h.LogSample) == 0
+}
+
+// recoverPanic recovers from panics in the request handler, and replies
+// with 500 Internal Server Error if nothing has been sent yet.
+func (h *Handler) recoverPanic(w *logWriter, r *http.Request) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if v == http.ErrAbortHandler {
+		panic(v)
+	}
+	if h.Debug {
+		log.Printf("httpxtra: panic serving %s %s: %v\n%s",
+			r.Method, r.URL, v, debug.Stack())
+	} else {
+		log.Printf("httpxtra: panic serving %s %s: %v",
+			r.Method, r.URL, v)
+	}
+	if h.PanicHandler != nil {
+		h.PanicHandler(r, v)
+	}
+	if w.status == 0 && !w.hijacked {
+		Error(w, r, http.StatusInternalServerError)
 	}
 }
@@ -0,0 +1,65 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// HealthCheck is a readiness check, like pinging a database. It returns
+// an error if the dependency is not available.
+type HealthCheck func(ctx context.Context) error
+
+// Health returns a handler for readiness probes, like those of Kubernetes.
+// It runs the checks concurrently with the context of the request, and
+// replies with 200 OK and {"status": "ok"} if all of them pass. Otherwise
+// it replies with 503 Service Unavailable and the errors of the failing
+// checks by name, like {"status": "fail", "errors": {"db": "..."}}.
+//
+// Usage:
+//
+//	remux.Handle("^/healthz$", httpxtra.Alive)
+//	remux.Handle("^/readyz$", httpxtra.Health(map[string]httpxtra.HealthCheck{
+//		"db": db.PingContext,
+//	}))
+func Health(checks map[string]HealthCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			mu   sync.Mutex
+			wg   sync.WaitGroup
+			errs map[string]string
+		)
+		for name, check := range checks {
+			wg.Add(1)
+			go func(name string, check HealthCheck) {
+				defer wg.Done()
+				if err := check(r.Context()); err != nil {
+					mu.Lock()
+					if errs == nil {
+						errs = make(map[string]string)
+					}
+					errs[name] = err.Error()
+					mu.Unlock()
+				}
+			}(name, check)
+		}
+		wg.Wait()
+		w.Header().Set("Cache-Control", "no-store")
+		if errs != nil {
+			WriteJSON(w, r, http.StatusServiceUnavailable, map[string]interface{}{
+				"status": "fail",
+				"errors": errs,
+			})
+			return
+		}
+		WriteJSON(w, r, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+// Alive is a handler for liveness probes, that replies with 200 OK and
+// {"status": "ok"} while the server is able to serve requests.
+var Alive = Health(nil)
@@ -0,0 +1,46 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"github.com/fiorix/go-web/autogzip"
+)
+
+// ErrNoHijack is returned by Hijack, and by the Hijack method of the
+// response writers of Handler, when the underlying connection can't be
+// hijacked. It's the same error as autogzip.ErrNoHijack, so it can be
+// compared no matter which writer returned it.
+var ErrNoHijack = autogzip.ErrNoHijack
+
+// Hijack lets the caller take over the connection of the response, for
+// example to upgrade it to a WebSocket. It returns ErrNoHijack if the
+// server doesn't support hijacking, like HTTP/2 servers.
+//
+// The response writers of Handler and autogzip support hijacking, and
+// stop processing the response once the connection is hijacked. Since
+// they implement http.Hijacker, WebSocket libraries that upgrade the
+// connection themselves work as usual, for example with gorilla/websocket:
+//
+//	var upgrader = websocket.Upgrader{}
+//
+//	func WSHandler(w http.ResponseWriter, r *http.Request) {
+//		conn, err := upgrader.Upgrade(w, r, nil)
+//		if err != nil {
+//			return // Upgrade already replied with an error
+//		}
+//		defer conn.Close()
+//		...
+//	}
+func Hijack(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, ErrNoHijack
+	}
+	return hj.Hijack()
+}
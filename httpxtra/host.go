@@ -0,0 +1,27 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"strings"
+
+	"github.com/fiorix/go-web/remux"
+)
+
+// hostAllowed checks whether the Host header v matches any of the hosts,
+// which may be wildcards like "*.example.com" for any subdomain.
+func hostAllowed(v string, hosts []string) bool {
+	name := remux.HostName(v)
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if name == h {
+			return true
+		}
+		if strings.HasPrefix(h, "*.") && strings.HasSuffix(name, h[1:]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the IP address of the client, without the port number.
+// It works for both IPv4 and IPv6 addresses.
+//
+// When the request is served by a Handler with XHeaders set, the address
+// comes from either the X-Real-IP or X-Forwarded-For HTTP header.
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return strings.Trim(r.RemoteAddr, "[]")
+	}
+	return ip
+}
+
+// ParseClientIP returns the IP address of the client, like ClientIP, as
+// a net.IP. It returns false if the address is not a valid IP address,
+// for example when set from a malformed header, so handlers that depend on
+// it can reply with 400 Bad Request.
+func ParseClientIP(r *http.Request) (net.IP, bool) {
+	ip := net.ParseIP(ClientIP(r))
+	return ip, ip != nil
+}
+
+// forwardedIP returns the client IP from the X-Real-IP or X-Forwarded-For
+// HTTP headers, or an empty string. X-Forwarded-For may be a list of
+// addresses, where the first is the client, followed by each proxy.
+// Addresses may be IPv4 or IPv6, with or without port numbers, and
+// invalid ones are ignored.
+//
+// If TrustedProxies is set, only X-Forwarded-For is used, and only when
+// the request comes from a trusted proxy. It's walked from right to left,
+// across all its header lines, skipping trusted proxies, until the first
+// untrusted address. X-Real-IP is ignored, since proxies usually pass it
+// on as sent by the client.
+func (h *Handler) forwardedIP(r *http.Request) string {
+	check := len(h.TrustedProxies) > 0
+	if check && !h.trusted(ClientIP(r)) {
+		return ""
+	}
+	if v := r.Header.Get("X-Real-IP"); v != "" && !check {
+		return normalizeIP(v)
+	}
+	ips := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	if !check {
+		return normalizeIP(ips[0])
+	}
+	for i := len(ips) - 1; i >= 0; i-- {
+		ip := normalizeIP(ips[i])
+		if i == 0 || ip == "" || !h.trusted(ip) {
+			return ip
+		}
+	}
+	return ""
+}
+
+// normalizeIP returns the IP address in v, which may have a port number
+// and brackets, like "[2001:db8::1]:8080", in canonical form. It returns
+// an empty string if v is not a valid address.
+func normalizeIP(v string) string {
+	v = strings.TrimSpace(v)
+	if host, _, err := net.SplitHostPort(v); err == nil {
+		v = host
+	}
+	ip := net.ParseIP(strings.Trim(v, "[]"))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// trusted checks whether ip belongs to TrustedProxies.
+func (h *Handler) trusted(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, n := range h.TrustedProxies {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// servedIP returns the ClientIP seen by a handler served by h for a
+// request from remoteAddr with the given headers.
+func servedIP(h Handler, remoteAddr string, header http.Header) string {
+	var ip string
+	h.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip = ClientIP(r)
+	})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = remoteAddr
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	return ip
+}
+
+func TestForwardedIP(t *testing.T) {
+	tests := []struct {
+		name     string
+		xheaders bool
+		header   http.Header
+		want     string
+	}{
+		{"XHeaders off", false, http.Header{
+			"X-Real-Ip":       {"198.51.100.7"},
+			"X-Forwarded-For": {"198.51.100.8"},
+		}, "192.0.2.1"},
+		{"no headers", true, nil, "192.0.2.1"},
+		{"X-Real-IP", true, http.Header{
+			"X-Real-Ip":       {"198.51.100.7"},
+			"X-Forwarded-For": {"198.51.100.8"},
+		}, "198.51.100.7"},
+		{"X-Real-IP with port", true, http.Header{
+			"X-Real-Ip": {"[2001:db8::7]:8080"},
+		}, "2001:db8::7"},
+		{"first X-Forwarded-For hop", true, http.Header{
+			"X-Forwarded-For": {"198.51.100.8, 10.0.0.2, 10.0.0.3"},
+		}, "198.51.100.8"},
+		{"first X-Forwarded-For line", true, http.Header{
+			"X-Forwarded-For": {"198.51.100.8", "10.0.0.2"},
+		}, "198.51.100.8"},
+		{"invalid X-Real-IP", true, http.Header{
+			"X-Real-Ip": {"bogus"},
+		}, "192.0.2.1"},
+		{"invalid X-Forwarded-For", true, http.Header{
+			"X-Forwarded-For": {"bogus, 198.51.100.8"},
+		}, "192.0.2.1"},
+		{"empty X-Forwarded-For", true, http.Header{
+			"X-Forwarded-For": {""},
+		}, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		h := Handler{XHeaders: tt.xheaders}
+		if got := servedIP(h, "192.0.2.1:1234", tt.header); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestForwardedIPTrustedProxies(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	h := Handler{XHeaders: true, TrustedProxies: []net.IPNet{*proxies}}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{
+		{"no headers", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"untrusted peer", "192.0.2.9:1234", http.Header{
+			"X-Forwarded-For": {"198.51.100.7"},
+		}, "192.0.2.9"},
+		{"spoofed X-Real-IP", "10.0.0.1:1234", http.Header{
+			"X-Real-Ip":       {"203.0.113.66"},
+			"X-Forwarded-For": {"198.51.100.7"},
+		}, "198.51.100.7"},
+		{"spoofed X-Real-IP alone", "10.0.0.1:1234", http.Header{
+			"X-Real-Ip": {"203.0.113.66"},
+		}, "10.0.0.1"},
+		{"spoofed leftmost X-Forwarded-For", "10.0.0.1:1234", http.Header{
+			"X-Forwarded-For": {"203.0.113.66, 198.51.100.7, 10.0.0.2"},
+		}, "198.51.100.7"},
+		{"multiple X-Forwarded-For lines", "10.0.0.1:1234", http.Header{
+			"X-Forwarded-For": {"203.0.113.66", "198.51.100.7, 10.0.0.2"},
+		}, "198.51.100.7"},
+		{"only trusted proxies", "10.0.0.1:1234", http.Header{
+			"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"},
+		}, "10.0.0.3"},
+		{"invalid hop", "10.0.0.1:1234", http.Header{
+			"X-Forwarded-For": {"198.51.100.7, bogus"},
+		}, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		if got := servedIP(h, tt.remoteAddr, tt.header); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+		valid      bool
+	}{
+		{"[::1]:8080", "::1", true},
+		{"::1", "::1", true},
+		{"[2001:db8::1]", "2001:db8::1", true},
+		{"[2001:db8::1]:443", "2001:db8::1", true},
+		{"192.0.2.1:1234", "192.0.2.1", true},
+		{"192.0.2.1", "192.0.2.1", true},
+		{"bogus", "bogus", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if got := ClientIP(r); got != tt.want {
+			t.Errorf("ClientIP(%q) = %q, want %q", tt.remoteAddr, got, tt.want)
+		}
+		if _, ok := ParseClientIP(r); ok != tt.valid {
+			t.Errorf("ParseClientIP(%q) valid = %v, want %v",
+				tt.remoteAddr, ok, tt.valid)
+		}
+	}
+}
+
+func TestNormalizeIP(t *testing.T) {
+	tests := []struct{ v, want string }{
+		{"[::1]:8080", "::1"},
+		{"::1", "::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"2001:0db8:0:0::0001", "2001:db8::1"},
+		{"::ffff:192.0.2.1", "192.0.2.1"},
+		{"192.0.2.1:1234", "192.0.2.1"},
+		{" 192.0.2.1 ", "192.0.2.1"},
+		{"bogus", ""},
+		{"[bogus]:80", ""},
+		{"192.0.2.256", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeIP(tt.v); got != tt.want {
+			t.Errorf("normalizeIP(%q) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+// keyStore is a RateLimitStore that records the keys it's given.
+type keyStore struct{ keys []string }
+
+func (s *keyStore) Take(key string, burst int, rate float64) (RateLimitStatus, error) {
+	s.keys = append(s.keys, key)
+	return RateLimitStatus{Allowed: true}, nil
+}
+
+func TestRateLimitKey(t *testing.T) {
+	tests := []struct{ remoteAddr, want string }{
+		{"[::1]:8080", "::1"},
+		{"[::1]:9090", "::1"},
+		{"::1", "::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"192.0.2.1:1234", "192.0.2.1"},
+	}
+	store := &keyStore{}
+	h := RateLimit(RateLimitOptions{Burst: 1, Rate: 1, Store: store})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if got := store.keys[len(store.keys)-1]; got != tt.want {
+			t.Errorf("%q: key %q, want %q", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// DefaultMaxJSONBytes is the default maximum size of request bodies
+// read by ReadJSON.
+const DefaultMaxJSONBytes = 1 << 20
+
+var (
+	ErrNotJSON      = errors.New("Content-Type is not application/json")
+	ErrEmptyBody    = errors.New("Request body is empty")
+	ErrBodyTooLarge = errors.New("Request body is too large")
+
+	ErrInvalidCallback = errors.New("Invalid JSONP callback name")
+)
+
+// jsonpCallback matches JavaScript identifiers and dotted paths of them,
+// like "cb" or "jQuery.cb_1".
+var jsonpCallback = regexp.MustCompile(
+	`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// JSONOptions configures ReadJSON.
+type JSONOptions struct {
+	// MaxBytes is the maximum size of the request body.
+	// Defaults to DefaultMaxJSONBytes.
+	MaxBytes int64
+
+	// AnyContentType skips the Content-Type check.
+	AnyContentType bool
+
+	// Strict rejects JSON objects with fields that don't exist in the
+	// destination struct, which are ignored otherwise, so typos in field
+	// names of clients are caught. It's the default for requests served by
+	// a Handler with StrictJSON set.
+	Strict bool
+}
+
+// ReadJSON reads the request body and decodes its JSON content into v.
+// Requests with a Content-Type other than application/json are rejected
+// with ErrNotJSON unless opts.AnyContentType is set. Bodies larger than
+// opts.MaxBytes, or than the MaxBodyBytes of the Handler serving the
+// request, are rejected with ErrBodyTooLarge. The opts argument may
+// be nil, meaning default options.
+func ReadJSON(r *http.Request, v interface{}, opts *JSONOptions) error {
+	if opts == nil {
+		opts = &JSONOptions{}
+	}
+	if !opts.AnyContentType && !isJSON(r.Header.Get("Content-Type")) {
+		return ErrNotJSON
+	}
+	b, err := readBody(r, opts.MaxBytes)
+	if err != nil {
+		return err
+	}
+	if opts.Strict || settings(r).StrictJSON {
+		err = decodeStrict(b, v)
+	} else {
+		err = json.Unmarshal(b, v)
+	}
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *json.SyntaxError:
+		return fmt.Errorf("Malformed JSON at offset %d: %s",
+			e.Offset, e.Error())
+	case *json.UnmarshalTypeError:
+		return fmt.Errorf("Invalid JSON value for %q at offset %d: "+
+			"expected %s, got %s", e.Field, e.Offset, e.Type, e.Value)
+	default:
+		return e
+	}
+}
+
+// decodeStrict decodes the JSON value in b into v, like json.Unmarshal,
+// but rejects unknown object fields.
+func decodeStrict(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("Malformed JSON at offset %d: "+
+				"unexpected end of JSON input", len(b))
+		}
+		if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return fmt.Errorf("Unknown JSON field %s", name)
+		}
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("Unexpected data after JSON value")
+	}
+	return nil
+}
+
+// readBody reads the request body, of up to max bytes, or
+// DefaultMaxJSONBytes if max is not positive. It returns ErrBodyTooLarge
+// for larger bodies, and ErrEmptyBody for empty ones.
+func readBody(r *http.Request, max int64) ([]byte, error) {
+	if max <= 0 {
+		max = DefaultMaxJSONBytes
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, ErrEmptyBody
+	}
+	b, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return nil, ErrBodyTooLarge
+	} else if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > max {
+		return nil, ErrBodyTooLarge
+	}
+	if len(b) == 0 {
+		return nil, ErrEmptyBody
+	}
+	return b, nil
+}
+
+// isJSON checks whether the given Content-Type is JSON, including the
+// application/*+json variants.
+func isJSON(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return t == "application/json" ||
+		strings.HasPrefix(t, "application/") && strings.HasSuffix(t, "+json")
+}
+
+// WriteJSON encodes v as JSON and writes it to w with the given status code.
+// Nothing is written if v can't be encoded, so the caller is still able to
+// send an error response. Responses are indented when the request is served
+// by a Handler with PrettyJSON set.
+func WriteJSON(w http.ResponseWriter, r *http.Request, code int, v interface{}) error {
+	var (
+		b   []byte
+		err error
+	)
+	if settings(r).PrettyJSON {
+		b, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		b, err = json.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// Created replies with 201 Created, a Location header pointing to the new
+// resource, and v encoded as JSON, like WriteJSON.
+func Created(w http.ResponseWriter, r *http.Request, location string, v interface{}) error {
+	w.Header().Set("Location", location)
+	return WriteJSON(w, r, http.StatusCreated, v)
+}
+
+// WriteJSONP encodes v as JSON and writes it to w wrapped in a call to the
+// JavaScript function named by the query parameter param, like
+// "cb({...});". Requests without the parameter get plain JSON, like
+// WriteJSON. Callback names other than JavaScript identifiers, optionally
+// dotted like "jQuery.cb", are rejected with ErrInvalidCallback and nothing
+// is written, so they can't inject code into the response.
+func WriteJSONP(w http.ResponseWriter, r *http.Request, param string, v interface{}) error {
+	cb := QueryString(r, param)
+	if cb == "" {
+		return WriteJSON(w, r, http.StatusOK, v)
+	}
+	if len(cb) > 128 || !jsonpCallback.MatchString(cb) {
+		return ErrInvalidCallback
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/javascript; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	// The leading comment prevents the response from being taken as
+	// other content types, like Flash, by old browsers.
+	_, err = fmt.Fprintf(w, "/**/%s(%s);\n", cb, b)
+	return err
+}
@@ -0,0 +1,52 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"net/http"
+	"time"
+)
+
+// LimitConcurrency returns a middleware that limits the number of requests
+// served at the same time to n. Requests over the limit wait up to wait
+// for another request to finish, and are then rejected with 503 Service
+// Unavailable and a Retry-After header. A zero wait rejects them right
+// away, which sheds load without queueing.
+func LimitConcurrency(n int, wait time.Duration) Middleware {
+	sem := make(chan struct{}, n)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !acquire(r, sem, wait) {
+				w.Header().Set("Retry-After", "1")
+				Error(w, r, http.StatusServiceUnavailable)
+				return
+			}
+			defer func() { <-sem }()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// acquire takes a slot of sem, waiting up to wait, or until the request
+// is canceled. It returns false if no slot was taken.
+func acquire(r *http.Request, sem chan struct{}, wait time.Duration) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+	if wait <= 0 {
+		return false
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-t.C:
+	case <-r.Context().Done():
+	}
+	return false
+}
@@ -7,18 +7,35 @@ package httpxtra
 import (
 	"bufio"
 	"fmt"
+	"log"
 	"net"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 )
 
 // LoggerFunc are functions called by httpxtra.Handler at the end of each request.
+// The request duration is time.Since(created), and bytes is the size of the
+// response body as sent to the client. When routing with remux, the pattern
+// that matched the request is available with remux.Route(r).
 type LoggerFunc func(r *http.Request, created time.Time, status, bytes int)
 
+// DefaultLogger is a LoggerFunc that writes Apache Combined access logs
+// to stderr, followed by the request ID, if any.
+func DefaultLogger(r *http.Request, created time.Time, status, bytes int) {
+	line := ApacheCombinedLog(r, created, status, bytes)
+	if id := RequestID(r); id != "" {
+		line += " " + strconv.Quote(id)
+	}
+	fmt.Fprintln(os.Stderr, line)
+}
+
 type logWriter struct {
-	w      http.ResponseWriter
-	bytes  int
-	status int
+	w        http.ResponseWriter
+	bytes    int
+	status   int
+	hijacked bool
 }
 
 func (lw *logWriter) Header() http.Header {
@@ -34,17 +51,52 @@ func (lw *logWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// WriteHeader sends the status code s, and records it for logging. Calls
+// after the status is sent are ignored with a warning, like net/http does,
+// so the log has the status actually sent. Informational 1xx responses may
+// be sent before the final status.
 func (lw *logWriter) WriteHeader(s int) {
+	if lw.status != 0 {
+		log.Printf("httpxtra: superfluous WriteHeader call with %d, "+
+			"status %d was already sent", s, lw.status)
+		return
+	}
 	lw.w.WriteHeader(s)
-	lw.status = s
+	if s >= 200 || s == http.StatusSwitchingProtocols {
+		lw.status = s
+	}
 }
 
-func (lw *logWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+func (lw *logWriter) Flush() {
 	if lw.status == 0 {
 		lw.status = http.StatusOK
 	}
-	// TODO: Check. Does it break if the server don't support hijacking?
-	return lw.w.(http.Hijacker).Hijack()
+	if f, ok := lw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (lw *logWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := lw.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, ErrNoHijack
+	}
+	conn, buf, err := hj.Hijack()
+	if err == nil {
+		lw.hijacked = true
+		if lw.status == 0 {
+			lw.status = http.StatusOK
+		}
+	}
+	return conn, buf, err
+}
+
+func (lw *logWriter) Written() bool {
+	return lw.status != 0 || lw.hijacked
+}
+
+func (lw *logWriter) Unwrap() http.ResponseWriter {
+	return lw.w
 }
 
 // ApacheCommonLog returns an Apache Common access log string.
@@ -55,12 +107,8 @@ func ApacheCommonLog(r *http.Request, created time.Time, status, bytes int) stri
 			u = name
 		}
 	}
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		ip = r.RemoteAddr
-	}
 	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %d",
-		ip,
+		ClientIP(r),
 		u,
 		created.Format("02/Jan/2006:15:04:05 -0700"),
 		r.Method,
@@ -70,3 +118,21 @@ func ApacheCommonLog(r *http.Request, created time.Time, status, bytes int) stri
 		bytes,
 	)
 }
+
+// ApacheCombinedLog returns an Apache Combined access log string, which is
+// the Common format followed by the Referer and User-Agent of the request.
+func ApacheCombinedLog(r *http.Request, created time.Time, status, bytes int) string {
+	return fmt.Sprintf("%s %s %s",
+		ApacheCommonLog(r, created, status, bytes),
+		quoteOrDash(r.Referer()),
+		quoteOrDash(r.UserAgent()),
+	)
+}
+
+// quoteOrDash returns s in double quotes, or "-" if s is empty.
+func quoteOrDash(s string) string {
+	if s == "" {
+		s = "-"
+	}
+	return strconv.Quote(s)
+}
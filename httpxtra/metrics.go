@@ -0,0 +1,192 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fiorix/go-web/remux"
+)
+
+// DefaultBuckets are the default latency histogram buckets of Metrics,
+// in seconds.
+var DefaultBuckets = []float64{
+	.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+}
+
+// Metrics collects the number of requests, requests in flight, and a
+// histogram of request latencies labeled by method, route and status
+// code. Metrics is an http.Handler that exposes them in the Prometheus
+// text format.
+//
+// Routes are the patterns of remux.Route, so the number of series is
+// bounded by the number of routes rather than URLs. Requests that didn't
+// match any route are labeled with an empty route, and those with
+// non-standard methods with the method "OTHER".
+//
+// Usage:
+//
+//	m := httpxtra.NewMetrics()
+//	remux.Handle("^/metrics$", m)
+//	s := http.Server{
+//		Addr: ":8080",
+//		Handler: httpxtra.Handler{
+//			Handler:    remux.DefaultServeMux,
+//			Middleware: []httpxtra.Middleware{m.Wrap},
+//		},
+//	}
+type Metrics struct {
+	buckets  []float64
+	inflight int64
+
+	mu     sync.Mutex
+	series map[metricKey]*metricSeries
+}
+
+type metricKey struct {
+	method, route string
+	code          int
+}
+
+type metricSeries struct {
+	count   uint64
+	sum     float64
+	buckets []uint64 // cumulative counts, per bucket
+}
+
+// NewMetrics returns a new Metrics with the given latency histogram
+// buckets, in seconds. It uses DefaultBuckets if none are given.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	b := make([]float64, len(buckets))
+	copy(b, buckets)
+	sort.Float64s(b)
+	return &Metrics{buckets: b, series: make(map[metricKey]*metricSeries)}
+}
+
+// ActiveRequests returns the number of requests being served, which is
+// also exported as http_requests_in_flight. During graceful shutdown, it
+// shows the progress of draining active requests.
+func (m *Metrics) ActiveRequests() int {
+	return int(atomic.LoadInt64(&m.inflight))
+}
+
+// Wrap is a Middleware that records metrics of requests served by next.
+func (m *Metrics) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := time.Now()
+		atomic.AddInt64(&m.inflight, 1)
+		defer atomic.AddInt64(&m.inflight, -1)
+		r = remux.Track(r)
+		lw := &logWriter{w: w}
+		next.ServeHTTP(lw, r)
+		if lw.status == 0 {
+			lw.status = http.StatusOK
+		}
+		m.observe(metricKey{metricMethod(r.Method), remux.Route(r), lw.status},
+			time.Since(t).Seconds())
+	})
+}
+
+// metricMethod returns the method label for method, which is "OTHER" for
+// non-standard methods, since clients can send any method.
+func metricMethod(method string) string {
+	switch method {
+	case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "CONNECT",
+		"OPTIONS", "TRACE":
+		return method
+	}
+	return "OTHER"
+}
+
+// observe records a request of the given duration, in seconds.
+func (m *Metrics) observe(k metricKey, d float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.series[k]
+	if s == nil {
+		s = &metricSeries{buckets: make([]uint64, len(m.buckets))}
+		m.series[k] = s
+	}
+	s.count++
+	s.sum += d
+	for n, le := range m.buckets {
+		if d <= le {
+			s.buckets[n]++
+		}
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats the labels of k, and extra labels.
+func (k metricKey) labels(extra string) string {
+	s := fmt.Sprintf(`method="%s",route="%s",code="%d"`,
+		labelEscaper.Replace(k.method),
+		labelEscaper.Replace(k.route),
+		k.code)
+	if extra != "" {
+		s += "," + extra
+	}
+	return "{" + s + "}"
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	keys := make([]metricKey, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	var b bytes.Buffer
+	b.WriteString("# HELP http_requests_total Total number of HTTP requests.\n")
+	b.WriteString("# TYPE http_requests_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "http_requests_total%s %d\n",
+			k.labels(""), m.series[k].count)
+	}
+	b.WriteString("# HELP http_requests_in_flight Number of HTTP requests being served.\n")
+	b.WriteString("# TYPE http_requests_in_flight gauge\n")
+	fmt.Fprintf(&b, "http_requests_in_flight %d\n", atomic.LoadInt64(&m.inflight))
+	b.WriteString("# HELP http_request_duration_seconds Latency of HTTP requests.\n")
+	b.WriteString("# TYPE http_request_duration_seconds histogram\n")
+	for _, k := range keys {
+		s := m.series[k]
+		for n, le := range m.buckets {
+			fmt.Fprintf(&b, "http_request_duration_seconds_bucket%s %d\n",
+				k.labels(`le="`+strconv.FormatFloat(le, 'g', -1, 64)+`"`),
+				s.buckets[n])
+		}
+		fmt.Fprintf(&b, "http_request_duration_seconds_bucket%s %d\n",
+			k.labels(`le="+Inf"`), s.count)
+		fmt.Fprintf(&b, "http_request_duration_seconds_sum%s %g\n",
+			k.labels(""), s.sum)
+		fmt.Fprintf(&b, "http_request_duration_seconds_count%s %d\n",
+			k.labels(""), s.count)
+	}
+	m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(b.Bytes())
+}
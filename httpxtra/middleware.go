@@ -0,0 +1,38 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import "net/http"
+
+// Middleware wraps an http.Handler with extra behavior.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h with the given middleware. The first middleware is the
+// outermost and runs first. Any middleware may stop the chain by not
+// calling the next handler.
+//
+// Usage:
+//
+//	func Quota(next http.Handler) http.Handler {
+//		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//			if overQuota(r) {
+//				http.Error(w, "Quota exceeded", 403)
+//				return
+//			}
+//			next.ServeHTTP(w, r)
+//		})
+//	}
+//
+//	func main() {
+//		h := http.HandlerFunc(LookupHandler)
+//		http.Handle("/lookup/", httpxtra.Chain(h, Quota, CORS))
+//		http.ListenAndServe(":8080", nil)
+//	}
+func Chain(h http.Handler, mw ...Middleware) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
@@ -0,0 +1,120 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptSpec is a media range of the Accept HTTP header.
+type acceptSpec struct {
+	typ, sub string
+	q        float64
+}
+
+// parseAccept parses the media ranges of an Accept HTTP header, and their
+// quality values. Malformed media ranges are ignored.
+func parseAccept(h string) []acceptSpec {
+	var specs []acceptSpec
+	for _, s := range strings.Split(h, ",") {
+		params := strings.Split(s, ";")
+		mt := strings.ToLower(strings.TrimSpace(params[0]))
+		n := strings.IndexByte(mt, '/')
+		if n < 1 || n == len(mt)-1 {
+			continue
+		}
+		spec := acceptSpec{typ: mt[:n], sub: mt[n+1:], q: 1}
+		for _, p := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.ToLower(strings.TrimSpace(k)) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				spec.q = q
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// Negotiate returns the best of the offered media types for the request,
+// based on the quality values of its Accept HTTP header, as described in
+// RFC 7231 section 5.3.2. It handles the */* and type/* wildcards, where
+// the most specific media range that matches an offer determines its
+// quality. Ties are resolved by the order of the offers.
+//
+// It returns the first offer if the request has no Accept header, or an
+// empty string if none of the offers are acceptable.
+//
+// Responses that depend on the result must have Accept in their Vary
+// header, so caches don't serve them to clients that accept other types,
+// see AddVary.
+//
+// Usage:
+//
+//	httpxtra.AddVary(w, "Accept")
+//	switch httpxtra.Negotiate(r, "application/json", "application/xml") {
+//	case "application/json":
+//		httpxtra.WriteJSON(w, r, http.StatusOK, v)
+//	case "application/xml":
+//		...
+//	default:
+//		http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
+//	}
+func Negotiate(r *http.Request, offers ...string) string {
+	h := r.Header.Get("Accept")
+	if h == "" {
+		if len(offers) > 0 {
+			return offers[0]
+		}
+		return ""
+	}
+	specs := parseAccept(h)
+	best, bestq := "", 0.0
+	for _, offer := range offers {
+		typ, sub, _ := strings.Cut(strings.ToLower(offer), "/")
+		q, prec := 0.0, -1
+		for _, s := range specs {
+			var p int
+			switch {
+			case s.typ == typ && s.sub == sub:
+				p = 2
+			case s.typ == typ && s.sub == "*":
+				p = 1
+			case s.typ == "*" && s.sub == "*":
+				p = 0
+			default:
+				continue
+			}
+			if p > prec {
+				q, prec = s.q, p
+			}
+		}
+		if q > bestq {
+			best, bestq = offer, q
+		}
+	}
+	return best
+}
+
+// AddVary adds the request header name to the Vary header of the
+// response, unless it's already there, so caches store a response for
+// each value of that request header. Responses negotiated with the Accept
+// or Accept-Encoding headers, or with CORS, need it.
+func AddVary(w http.ResponseWriter, name string) {
+	h := w.Header()
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimSpace(f)
+			if f == "*" || strings.EqualFold(f, name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", http.CanonicalHeaderKey(name))
+}
@@ -0,0 +1,102 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CheckPrecondition evaluates the conditional headers of the request
+// against the current ETag and modification time of the resource, as
+// described in RFC 9110 section 13.2.2. Either may be empty or zero when
+// unknown, and an empty ETag means the resource doesn't exist.
+//
+// It returns true if the request should proceed. Otherwise it returns the
+// status code of the response: 412 Precondition Failed when If-Match or
+// If-Unmodified-Since fail, as for writes based on a stale copy of the
+// resource, or 304 Not Modified for GET and HEAD requests with If-None-Match
+// or If-Modified-Since whose copy is still valid.
+//
+// Usage:
+//
+//	func PutHandler(w http.ResponseWriter, r *http.Request) {
+//		doc := load(...)
+//		if ok, code := httpxtra.CheckPrecondition(r, doc.ETag, doc.Modified); !ok {
+//			w.WriteHeader(code)
+//			return
+//		}
+//		... // update doc
+//	}
+func CheckPrecondition(r *http.Request, etag string, modtime time.Time) (bool, int) {
+	modtime = modtime.Truncate(time.Second) // HTTP dates have seconds
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !matchETag(im, etag, false) {
+			return false, http.StatusPreconditionFailed
+		}
+	} else if t, ok := headerTime(r, "If-Unmodified-Since"); ok && !modtime.IsZero() {
+		if modtime.After(t) {
+			return false, http.StatusPreconditionFailed
+		}
+	}
+	get := r.Method == "GET" || r.Method == "HEAD"
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !matchETag(inm, etag, true) {
+			return true, 0
+		}
+		if get {
+			return false, http.StatusNotModified
+		}
+		return false, http.StatusPreconditionFailed
+	}
+	if t, ok := headerTime(r, "If-Modified-Since"); ok && get && !modtime.IsZero() {
+		if !modtime.After(t) {
+			return false, http.StatusNotModified
+		}
+	}
+	return true, 0
+}
+
+// headerTime returns the time of the HTTP date header name, if valid.
+func headerTime(r *http.Request, name string) (time.Time, bool) {
+	t, err := http.ParseTime(r.Header.Get(name))
+	return t, err == nil
+}
+
+// matchETag checks whether etag matches any of the entity tags in the
+// header value v, or "*" for any existing resource. Weak comparison, for
+// If-None-Match, ignores the W/ prefix of weak tags, while strong
+// comparison, for If-Match, doesn't match weak tags at all.
+func matchETag(v, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(v) == "*" {
+		return true
+	}
+	etagWeak := strings.HasPrefix(etag, "W/")
+	etag = strings.TrimPrefix(etag, "W/")
+	for {
+		v = strings.TrimLeft(v, " \t,")
+		if v == "" {
+			return false
+		}
+		isWeak := strings.HasPrefix(v, "W/")
+		v = strings.TrimPrefix(v, "W/")
+		if len(v) < 2 || v[0] != '"' {
+			return false // malformed
+		}
+		n := strings.IndexByte(v[1:], '"')
+		if n < 0 {
+			return false
+		}
+		tag := v[:n+2]
+		v = v[n+2:]
+		if tag == etag && (weak || !isWeak && !etagWeak) {
+			return true
+		}
+	}
+}
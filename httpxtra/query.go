@@ -0,0 +1,56 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Query returns the parsed URL query of the request. Requests served by
+// Handler parse the query only once.
+func Query(r *http.Request) url.Values {
+	s := getState(r)
+	if s == nil {
+		return r.URL.Query()
+	}
+	if s.query == nil {
+		s.query = r.URL.Query()
+	}
+	return s.query
+}
+
+// QueryString returns the first value of the named query parameter, or
+// an empty string.
+func QueryString(r *http.Request, name string) string {
+	return Query(r).Get(name)
+}
+
+// QueryInt returns the first value of the named query parameter as an int.
+// It returns def if the parameter is missing or is not an integer.
+func QueryInt(r *http.Request, name string, def int) int {
+	v, err := strconv.Atoi(Query(r).Get(name))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// QueryBool returns the first value of the named query parameter as a bool.
+// Parameters without value, like "?debug", are true. It returns false if the
+// parameter is missing or is not a boolean.
+func QueryBool(r *http.Request, name string) bool {
+	q := Query(r)
+	vs, ok := q[name]
+	if !ok {
+		return false
+	}
+	if len(vs) == 0 || vs[0] == "" {
+		return true
+	}
+	v, _ := strconv.ParseBool(vs[0])
+	return v
+}
@@ -0,0 +1,163 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitOptions configures the RateLimit middleware, which implements
+// a token bucket per client: each request takes one token from a bucket
+// of Burst tokens, refilled at Rate tokens per second.
+type RateLimitOptions struct {
+	// Burst is the maximum number of requests allowed at once.
+	Burst int
+
+	// Rate is the number of requests per second allowed in the long run.
+	Rate float64
+
+	// Key returns the key of the client making the request.
+	// Defaults to ClientIP.
+	Key func(r *http.Request) string
+
+	// Store keeps the buckets. Defaults to an in-memory store.
+	Store RateLimitStore
+
+	// StatusCode is the status of responses to requests over the limit.
+	// Defaults to 429 Too Many Requests. Legacy clients may need 403.
+	StatusCode int
+}
+
+// RateLimitStatus is the state of a bucket after taking a token.
+type RateLimitStatus struct {
+	Allowed    bool          // whether there was a token to take
+	Remaining  int           // tokens left in the bucket
+	Reset      time.Duration // time until the bucket is full again
+	RetryAfter time.Duration // time until the next token, if not allowed
+}
+
+// RateLimitStore keeps the token buckets of RateLimit. Implementations
+// must be safe for concurrent use.
+type RateLimitStore interface {
+	// Take takes one token from the bucket of key, which holds up to
+	// burst tokens and is refilled at rate tokens per second.
+	Take(key string, burst int, rate float64) (RateLimitStatus, error)
+}
+
+// RateLimit returns a middleware that limits the rate of requests per
+// client. Every response has the X-RateLimit-Limit, X-RateLimit-Remaining
+// and X-RateLimit-Reset headers, and requests over the limit are rejected
+// with StatusCode and a Retry-After header, which is the time until the
+// client can make another request.
+//
+// Errors from the store are logged, and the request is allowed.
+//
+// Usage:
+//
+//	limit := httpxtra.RateLimit(httpxtra.RateLimitOptions{
+//		Burst: 10,
+//		Rate:  1,
+//	})
+//	http.Handle("/lookup/", httpxtra.Chain(lookup, limit))
+func RateLimit(opts RateLimitOptions) Middleware {
+	if opts.Key == nil {
+		opts.Key = ClientIP
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryRateLimitStore()
+	}
+	if opts.StatusCode == 0 {
+		opts.StatusCode = http.StatusTooManyRequests
+	}
+	limit := strconv.Itoa(opts.Burst)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			st, err := opts.Store.Take(opts.Key(r), opts.Burst, opts.Rate)
+			if err != nil {
+				log.Println("httpxtra: rate limit:", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", limit)
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(st.Remaining))
+			h.Set("X-RateLimit-Reset", seconds(st.Reset))
+			if !st.Allowed {
+				h.Set("Retry-After", seconds(st.RetryAfter))
+				Error(w, r, opts.StatusCode)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds formats d as a number of seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// NewMemoryRateLimitStore returns an in-memory RateLimitStore. Buckets
+// that are full again are periodically evicted.
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{m: make(map[string]*tokenBucket)}
+}
+
+type memoryRateLimitStore struct {
+	mu    sync.Mutex
+	m     map[string]*tokenBucket
+	sweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time // time tokens was last updated
+	full   time.Time // time the bucket is full again
+}
+
+func (s *memoryRateLimitStore) Take(key string, burst int, rate float64) (RateLimitStatus, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.sweep) > time.Minute {
+		for k, b := range s.m {
+			if !now.Before(b.full) {
+				delete(s.m, k)
+			}
+		}
+		s.sweep = now
+	}
+	b := s.m[key]
+	if b == nil {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		s.m[key] = b
+	}
+	b.tokens = math.Min(float64(burst),
+		b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	var st RateLimitStatus
+	if b.tokens >= 1 {
+		b.tokens--
+		st.Allowed = true
+	} else if rate > 0 {
+		st.RetryAfter = rateDuration(1-b.tokens, rate)
+	}
+	if rate > 0 {
+		st.Reset = rateDuration(float64(burst)-b.tokens, rate)
+	}
+	b.full = now.Add(st.Reset)
+	st.Remaining = int(b.tokens)
+	return st, nil
+}
+
+// rateDuration returns the time to get n tokens at the given rate.
+func rateDuration(n, rate float64) time.Duration {
+	return time.Duration(n / rate * float64(time.Second))
+}
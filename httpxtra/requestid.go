@@ -0,0 +1,49 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader is the header that carries request IDs.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID returns the ID of the request, if served by a Handler with
+// RequestID set, or an empty string.
+func RequestID(r *http.Request) string {
+	if s := getState(r); s != nil {
+		return s.requestID
+	}
+	return ""
+}
+
+// requestID returns the request ID sent by the client or a proxy, if it
+// looks safe for logging, or a new random ID.
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID checks whether id is up to 128 printable ASCII characters,
+// other than quotes and backslashes.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if c <= ' ' || c > '~' || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
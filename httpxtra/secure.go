@@ -0,0 +1,96 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// SecureHeadersOptions configures the SecureHeaders middleware. Headers
+// with empty or zero values are not set.
+type SecureHeadersOptions struct {
+	// NoSniff sets "X-Content-Type-Options: nosniff", which stops
+	// browsers from guessing content types.
+	NoSniff bool
+
+	// FrameOptions is the X-Frame-Options header, like "DENY" or
+	// "SAMEORIGIN", which protects against clickjacking.
+	FrameOptions string
+
+	// ReferrerPolicy is the Referrer-Policy header, like
+	// "strict-origin-when-cross-origin".
+	ReferrerPolicy string
+
+	// ContentSecurityPolicy is the Content-Security-Policy header, like
+	// "default-src 'self'".
+	ContentSecurityPolicy string
+
+	// HSTSMaxAge is how long, in seconds, browsers must only use HTTPS
+	// for the site. It's sent in the Strict-Transport-Security header of
+	// HTTPS responses only.
+	HSTSMaxAge int
+
+	// HSTSIncludeSubdomains applies HSTS to all subdomains.
+	HSTSIncludeSubdomains bool
+
+	// HSTSPreload allows the site in the HSTS preload lists of browsers.
+	HSTSPreload bool
+}
+
+// DefaultSecureHeaders are reasonable options for the SecureHeaders
+// middleware. They don't include a Content-Security-Policy, which depends
+// on the site.
+var DefaultSecureHeaders = SecureHeadersOptions{
+	NoSniff:        true,
+	FrameOptions:   "DENY",
+	ReferrerPolicy: "strict-origin-when-cross-origin",
+	HSTSMaxAge:     180 * 24 * 3600,
+}
+
+// SecureHeaders returns a middleware that sets security related headers
+// on all responses.
+//
+// Usage:
+//
+//	opts := httpxtra.DefaultSecureHeaders
+//	opts.ContentSecurityPolicy = "default-src 'self'"
+//	h := httpxtra.Handler{
+//		Middleware: []httpxtra.Middleware{httpxtra.SecureHeaders(opts)},
+//	}
+func SecureHeaders(opts SecureHeadersOptions) Middleware {
+	hsts := ""
+	if opts.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(opts.HSTSMaxAge)
+		if opts.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if opts.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			if opts.NoSniff {
+				h.Set("X-Content-Type-Options", "nosniff")
+			}
+			if opts.FrameOptions != "" {
+				h.Set("X-Frame-Options", opts.FrameOptions)
+			}
+			if opts.ReferrerPolicy != "" {
+				h.Set("Referrer-Policy", opts.ReferrerPolicy)
+			}
+			if opts.ContentSecurityPolicy != "" {
+				h.Set("Content-Security-Policy",
+					opts.ContentSecurityPolicy)
+			}
+			if hsts != "" && r.TLS != nil {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -5,25 +5,385 @@
 package httpxtra
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 )
 
 // ListenAndServe can listen on both TCP and UNIX sockets.
 func ListenAndServe(srv http.Server) error {
-	var proto string
-	if srv.Addr == "" {
-		srv.Addr = ":http"
-	}
-	if strings.Contains(srv.Addr, "/") {
-		proto = "unix"
-	} else {
-		proto = "tcp"
-	}
-	l, e := net.Listen(proto, srv.Addr)
+	l, e := listen(srv.Addr)
 	if e != nil {
 		return e
 	}
 	return srv.Serve(l)
 }
+
+// listen creates a TCP or UNIX socket listener for addr. Addresses
+// containing a slash are UNIX sockets. Stale UNIX socket files, left
+// behind by a crash, are removed first.
+func listen(addr string) (net.Listener, error) {
+	if addr == "" {
+		addr = ":http"
+	}
+	if !isUnix(addr) {
+		return net.Listen("tcp", addr)
+	}
+	removeStaleSocket(addr)
+	return net.Listen("unix", addr)
+}
+
+// isUnix checks whether addr is a UNIX socket address.
+func isUnix(addr string) bool {
+	return strings.Contains(addr, "/")
+}
+
+// removeStaleSocket removes the UNIX socket file at addr if no server is
+// listening on it.
+func removeStaleSocket(addr string) {
+	fi, err := os.Lstat(addr)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if c, err := net.Dial("unix", addr); err == nil {
+		c.Close() // in use
+		return
+	}
+	os.Remove(addr)
+}
+
+// DefaultUnixSocketMode is the default file mode of UNIX sockets created
+// by Server.
+const DefaultUnixSocketMode os.FileMode = 0660
+
+// Server is an http.Server that can be shut down gracefully, and serve
+// HTTPS when configured with a certificate. Settings like ReadTimeout and
+// MaxHeaderBytes are those of the embedded http.Server.
+type Server struct {
+	http.Server
+
+	// DrainTimeout is the maximum time to wait for active requests to
+	// finish during shutdown. Zero means no limit.
+	DrainTimeout time.Duration
+
+	// CertFile and KeyFile are the certificate and matching private key
+	// for serving HTTPS. They're not required if TLSConfig already has
+	// certificates.
+	CertFile string
+	KeyFile  string
+
+	// RedirectAddr is an optional address for a plain HTTP server that
+	// redirects all requests to HTTPS. It's only used along with TLS.
+	RedirectAddr string
+
+	// UnixSocketMode is the file mode of the socket when Addr is a UNIX
+	// socket. Defaults to DefaultUnixSocketMode. The socket file is
+	// removed when the server shuts down.
+	UnixSocketMode os.FileMode
+
+	// DisableHTTP2 disables HTTP/2, which is otherwise enabled for HTTPS.
+	// HTTP/2 multiplexes requests over a single connection, which cuts the
+	// latency of pages with many small assets, at the cost of more memory
+	// and CPU per connection.
+	DisableHTTP2 bool
+
+	// H2C enables unencrypted HTTP/2 on plain HTTP servers, for servers
+	// sitting behind proxies that speak HTTP/2 to their backends. Clients
+	// must know the server supports it, since there's no negotiation.
+	H2C bool
+
+	// DisableKeepAlives disables HTTP keep-alives, so each connection
+	// serves a single request. Some load balancers balance connections
+	// rather than requests, and need this to spread the load evenly.
+	DisableKeepAlives bool
+
+	// OnShutdown are functions for cleaning up resources used by handlers,
+	// like database connections. They're called in order when the server
+	// shuts down, after active requests finish, with the context that
+	// limits the shutdown to DrainTimeout. Errors are logged, and returned
+	// along with any error that stopped the server.
+	OnShutdown []func(context.Context) error
+
+	// OnStart is an optional function called after the server listens on
+	// its address, and before it serves any request, for preparing things
+	// like caches, or checking that a database can be opened. If it fails,
+	// the server doesn't start, and its error is returned.
+	OnStart func() error
+}
+
+// ListenAndServeContext listens on the TCP or UNIX socket address srv.Addr
+// and serves requests until ctx is done. Then it stops accepting new
+// connections and waits up to DrainTimeout for active requests to finish.
+//
+// It serves HTTPS if CertFile and KeyFile are set, or TLSConfig has
+// certificates.
+//
+// It returns nil after a graceful shutdown, or the error that stopped the
+// server otherwise.
+//
+// Usage:
+//
+//	func main() {
+//		ctx, stop := signal.NotifyContext(context.Background(),
+//			os.Interrupt, syscall.SIGTERM)
+//		defer stop()
+//		srv := &httpxtra.Server{DrainTimeout: 10 * time.Second}
+//		srv.Addr = ":8080"
+//		srv.Handler = httpxtra.Handler{Logger: logger}
+//		if err := srv.ListenAndServeContext(ctx); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+func (srv *Server) ListenAndServeContext(ctx context.Context) error {
+	l, err := srv.listen()
+	if err != nil {
+		return err
+	}
+	return srv.ServeContext(ctx, l)
+}
+
+// ServeContext is like ListenAndServeContext, but serves requests on the
+// given listener, which is closed when the server shuts down. It's useful
+// for listeners inherited from systemd socket activation or from a parent
+// process during upgrades, and for ephemeral ports in tests, like
+// net.Listen("tcp", "127.0.0.1:0").
+func (srv *Server) ServeContext(ctx context.Context, l net.Listener) error {
+	var rl net.Listener
+	if srv.redirects() {
+		var err error
+		if rl, err = listen(srv.RedirectAddr); err != nil {
+			l.Close()
+			return err
+		}
+	}
+	if srv.OnStart != nil {
+		if err := srv.OnStart(); err != nil {
+			l.Close()
+			if rl != nil {
+				rl.Close()
+			}
+			return err
+		}
+	}
+	servers, serve := srv.servers(l, rl)
+	return runServers(ctx, srv.DrainTimeout, servers, serve, srv.OnShutdown)
+}
+
+// ListenAndServeAll runs multiple servers at once, like ListenAndServeContext,
+// until ctx is done or any of them fails. Then all servers are shut down
+// together, waiting up to the longest DrainTimeout for active requests to
+// finish, and the OnShutdown functions of all servers are called. The
+// OnStart functions are called once all servers listen, and before any of
+// them serves requests. Servers may share the same Handler, for example to
+// serve both HTTP and HTTPS:
+//
+//	h := httpxtra.Handler{Handler: mux, Logger: logger}
+//	plain := &httpxtra.Server{}
+//	plain.Addr, plain.Handler = ":8080", h
+//	tls := &httpxtra.Server{CertFile: "cert.pem", KeyFile: "key.pem"}
+//	tls.Addr, tls.Handler = ":8443", h
+//	err := httpxtra.ListenAndServeAll(ctx, plain, tls)
+func ListenAndServeAll(ctx context.Context, srvs ...*Server) error {
+	var (
+		ls      []net.Listener
+		servers []*http.Server
+		serve   []func() error
+		hooks   []func(context.Context) error
+		drain   time.Duration
+	)
+	closeAll := func() {
+		for _, l := range ls {
+			l.Close()
+		}
+	}
+	for _, srv := range srvs {
+		l, err := srv.listen()
+		if err != nil {
+			closeAll()
+			return err
+		}
+		ls = append(ls, l)
+		var rl net.Listener
+		if srv.redirects() {
+			if rl, err = listen(srv.RedirectAddr); err != nil {
+				closeAll()
+				return err
+			}
+			ls = append(ls, rl)
+		}
+		s, fn := srv.servers(l, rl)
+		servers = append(servers, s...)
+		serve = append(serve, fn...)
+		hooks = append(hooks, srv.OnShutdown...)
+		if srv.DrainTimeout > drain {
+			drain = srv.DrainTimeout
+		}
+	}
+	for _, srv := range srvs {
+		if srv.OnStart == nil {
+			continue
+		}
+		if err := srv.OnStart(); err != nil {
+			closeAll()
+			return err
+		}
+	}
+	return runServers(ctx, drain, servers, serve, hooks)
+}
+
+// listen creates the listener for srv.Addr, setting the file mode of UNIX
+// sockets.
+func (srv *Server) listen() (net.Listener, error) {
+	l, err := listen(srv.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if isUnix(srv.Addr) {
+		mode := srv.UnixSocketMode
+		if mode == 0 {
+			mode = DefaultUnixSocketMode
+		}
+		if err = os.Chmod(srv.Addr, mode); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// redirects checks whether the server has a plain HTTP server that
+// redirects to HTTPS.
+func (srv *Server) redirects() bool {
+	return srv.RedirectAddr != "" && srv.isTLS()
+}
+
+// servers configures srv for serving on l, and returns it along with the
+// redirect server on rl, if any, and their serve functions for runServers.
+func (srv *Server) servers(l, rl net.Listener) ([]*http.Server, []func() error) {
+	srv.setProtocols()
+	if srv.DisableKeepAlives {
+		srv.SetKeepAlivesEnabled(false)
+	}
+	servers := []*http.Server{&srv.Server}
+	serve := []func() error{func() error { return srv.serve(l) }}
+	if rl != nil {
+		addr := srv.Addr
+		if addr == "" {
+			addr = l.Addr().String()
+		}
+		rs := &http.Server{
+			Handler:  RedirectHTTPS(addr),
+			ErrorLog: srv.ErrorLog,
+		}
+		servers = append(servers, rs)
+		serve = append(serve, func() error { return rs.Serve(rl) })
+	}
+	return servers, serve
+}
+
+// isTLS checks whether the server is configured for HTTPS.
+func (srv *Server) isTLS() bool {
+	if srv.CertFile != "" && srv.KeyFile != "" {
+		return true
+	}
+	c := srv.TLSConfig
+	return c != nil && (len(c.Certificates) > 0 || c.GetCertificate != nil)
+}
+
+// setProtocols configures the HTTP versions of the server, unless set
+// with Protocols already.
+func (srv *Server) setProtocols() {
+	if srv.Protocols != nil || !srv.DisableHTTP2 && !srv.H2C {
+		return
+	}
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	if !srv.DisableHTTP2 {
+		p.SetHTTP2(true)
+		p.SetUnencryptedHTTP2(srv.H2C)
+	}
+	srv.Protocols = p
+}
+
+// serve serves HTTP or HTTPS on l, depending on the configuration.
+func (srv *Server) serve(l net.Listener) error {
+	if srv.isTLS() {
+		return srv.ServeTLS(l, srv.CertFile, srv.KeyFile)
+	}
+	return srv.Serve(l)
+}
+
+// runServers runs the serve functions until ctx is done or any of them
+// fails, then shuts all servers down, waiting up to drain for active
+// requests to finish, and calls the shutdown hooks. It returns the first
+// error other than http.ErrServerClosed, joined with those of the hooks.
+func runServers(ctx context.Context, drain time.Duration,
+	servers []*http.Server, serve []func() error,
+	hooks []func(context.Context) error) error {
+	errc := make(chan error, len(serve))
+	for _, fn := range serve {
+		go func(fn func() error) { errc <- fn() }(fn)
+	}
+	var (
+		err  error
+		done int
+	)
+	select {
+	case err = <-errc:
+		done++
+	case <-ctx.Done():
+	}
+	if err == http.ErrServerClosed {
+		err = nil
+	}
+	sctx := context.Background()
+	if drain > 0 {
+		var cancel context.CancelFunc
+		sctx, cancel = context.WithTimeout(sctx, drain)
+		defer cancel()
+	}
+	for _, s := range servers {
+		if e := s.Shutdown(sctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	for ; done < len(serve); done++ {
+		if e := <-errc; e != http.ErrServerClosed && err == nil {
+			err = e
+		}
+	}
+	for _, fn := range hooks {
+		if e := fn(sctx); e != nil {
+			log.Printf("httpxtra: shutdown hook: %v", e)
+			err = errors.Join(err, e)
+		}
+	}
+	return err
+}
+
+// RedirectHTTPS returns a handler that redirects all requests to the
+// HTTPS server listening on addr, keeping the host name, path and query
+// of the request.
+func RedirectHTTPS(addr string) http.Handler {
+	_, port, _ := net.SplitHostPort(addr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.Trim(r.Host, "[]")
+		}
+		if port != "" && port != "443" && port != "https" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		u := *r.URL
+		u.Scheme = "https"
+		u.Host = host
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,354 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionStore keeps the data of sessions. Implementations must be safe
+// for concurrent use.
+type SessionStore interface {
+	// Load returns the data of the session id, or nil if it doesn't
+	// exist or has expired.
+	Load(id string) (map[string]string, error)
+
+	// Save stores the data of the session id, which expires after ttl.
+	Save(id string, data map[string]string, ttl time.Duration) error
+
+	// Delete removes the session id.
+	Delete(id string) error
+}
+
+// SessionOptions configures the Sessions middleware.
+type SessionOptions struct {
+	// Store keeps the session data. Defaults to an in-memory store,
+	// which is lost on restarts and not shared between servers.
+	Store SessionStore
+
+	// Secret is the key for signing session cookies with HMAC-SHA256,
+	// which must be long and random. Defaults to the CookieSecret of the
+	// Handler serving the request.
+	Secret []byte
+
+	// Cookie is the template for session cookies, with attributes like
+	// Path, Domain, Secure, HttpOnly and SameSite. The Name defaults to
+	// "session" and the Path to "/".
+	Cookie http.Cookie
+
+	// MaxAge is how long sessions last after they're last modified.
+	// Defaults to 24 hours.
+	MaxAge time.Duration
+}
+
+// Session holds the data of the session of a client. Its methods are
+// safe for concurrent use.
+//
+// Sessions get their cookie when first modified in a request, which must
+// happen before the response headers are sent. The cookie is sent again
+// by every request that modifies the session, so it expires MaxAge after
+// the last modification, like the session in the store. Changes are saved
+// to the store after the handler returns.
+type Session struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	opts    *SessionOptions
+	secret  []byte
+	id      string
+	old     string // id of a renewed session, to be deleted
+	data    map[string]string
+	isNew   bool
+	changed bool
+	sent    bool // whether the cookie was set in this response
+}
+
+// flashPrefix is the prefix of the keys of flash messages.
+const flashPrefix = "_flash."
+
+// Sessions returns a middleware that provides sessions, available to
+// handlers via GetSession.
+//
+// Usage:
+//
+//	sessions := httpxtra.Sessions(httpxtra.SessionOptions{
+//		Secret: secret,
+//		Cookie: http.Cookie{HttpOnly: true, Secure: true,
+//			SameSite: http.SameSiteLaxMode},
+//	})
+//
+//	func SigninHandler(w http.ResponseWriter, r *http.Request) {
+//		s := httpxtra.GetSession(r)
+//		s.Renew()
+//		s.Set("user", user)
+//		s.SetFlash("message", "Welcome back!")
+//		http.Redirect(w, r, "/", http.StatusFound)
+//	}
+func Sessions(opts SessionOptions) Middleware {
+	if opts.Store == nil {
+		opts.Store = NewMemorySessionStore()
+	}
+	if opts.Cookie.Name == "" {
+		opts.Cookie.Name = "session"
+	}
+	if opts.Cookie.Path == "" {
+		opts.Cookie.Path = "/"
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = 24 * time.Hour
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s := opts.load(w, r)
+			if len(s.secret) == 0 {
+				log.Println("httpxtra: session:", ErrNoSecret)
+				Error(w, r, http.StatusInternalServerError)
+				return
+			}
+			ctx := context.WithValue(r.Context(), sessionKey, s)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			if err := s.save(); err != nil {
+				log.Println("httpxtra: session:", err)
+			}
+		})
+	}
+}
+
+// load returns the session of the request, or a new one.
+func (o *SessionOptions) load(w http.ResponseWriter, r *http.Request) *Session {
+	s := &Session{w: w, opts: o, secret: o.Secret}
+	if len(s.secret) == 0 {
+		s.secret = settings(r).CookieSecret
+		if len(s.secret) == 0 {
+			return s
+		}
+	}
+	if c, err := r.Cookie(o.Cookie.Name); err == nil {
+		if id, ok := verifyValue(s.secret, o.Cookie.Name, c.Value); ok {
+			data, err := o.Store.Load(id)
+			if err != nil {
+				log.Println("httpxtra: session:", err)
+			}
+			if data != nil {
+				s.id, s.data = id, data
+				return s
+			}
+		}
+	}
+	s.id, s.data, s.isNew = newSessionID(), make(map[string]string), true
+	return s
+}
+
+// GetSession returns the session of the request, provided by the Sessions
+// middleware, or nil.
+func GetSession(r *http.Request) *Session {
+	s, _ := r.Context().Value(sessionKey).(*Session)
+	return s
+}
+
+// Get returns the value of key, or an empty string.
+func (s *Session) Get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[key]
+}
+
+// Set sets the value of key.
+func (s *Session) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+	s.modified()
+}
+
+// Delete removes key.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[key]; ok {
+		delete(s.data, key)
+		s.modified()
+	}
+}
+
+// SetFlash sets a flash message, which is removed when read by Flash,
+// usually in the next request.
+func (s *Session) SetFlash(key, value string) {
+	s.Set(flashPrefix+key, value)
+}
+
+// Flash returns the flash message of key and removes it, or returns an
+// empty string.
+func (s *Session) Flash(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[flashPrefix+key]
+	if ok {
+		delete(s.data, flashPrefix+key)
+		s.modified()
+	}
+	return v
+}
+
+// Renew gives the session a new id, keeping its data. It should be called
+// when users sign in, to prevent session fixation attacks.
+func (s *Session) Renew() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isNew && s.old == "" {
+		s.old = s.id
+	}
+	s.id, s.isNew, s.sent = newSessionID(), true, false
+	s.modified()
+}
+
+// Destroy removes the session and its cookie. A new session is started
+// if it's modified afterwards.
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isNew && s.old == "" {
+		s.old = s.id
+	}
+	s.id, s.data, s.isNew = newSessionID(), make(map[string]string), true
+	s.changed, s.sent = false, false
+	c := s.opts.Cookie
+	c.Value, c.MaxAge = "", -1
+	http.SetCookie(s.w, &c)
+}
+
+// modified marks the session as changed, and sets its cookie, renewing
+// its MaxAge, unless already set.
+func (s *Session) modified() {
+	s.changed, s.isNew = true, false
+	if s.sent {
+		return
+	}
+	s.sent = true
+	c := s.opts.Cookie
+	c.Value = signValue(s.secret, c.Name, s.id)
+	if c.MaxAge == 0 {
+		c.MaxAge = int(s.opts.MaxAge / time.Second)
+	}
+	http.SetCookie(s.w, &c)
+}
+
+// save persists the changes of the session to the store.
+func (s *Session) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.old != "" {
+		if err := s.opts.Store.Delete(s.old); err != nil {
+			return err
+		}
+		s.old = ""
+	}
+	if !s.changed {
+		return nil
+	}
+	data := make(map[string]string, len(s.data))
+	for k, v := range s.data {
+		data[k] = v
+	}
+	return s.opts.Store.Save(s.id, data, s.opts.MaxAge)
+}
+
+// newSessionID returns a new random session id.
+func newSessionID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signValue returns value followed by its HMAC-SHA256 signature, which is
+// bound to the cookie name so values can't be moved between cookies.
+func signValue(secret []byte, name, value string) string {
+	return value + "." + base64.RawURLEncoding.EncodeToString(
+		mac(secret, name, value))
+}
+
+// verifyValue returns the value of signed, if its signature is valid.
+func verifyValue(secret []byte, name, signed string) (string, bool) {
+	n := strings.LastIndexByte(signed, '.')
+	if n < 0 {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signed[n+1:])
+	if err != nil || !hmac.Equal(sig, mac(secret, name, signed[:n])) {
+		return "", false
+	}
+	return signed[:n], true
+}
+
+// mac returns the HMAC-SHA256 of name and value.
+func mac(secret []byte, name, value string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(value))
+	return h.Sum(nil)
+}
+
+// NewMemorySessionStore returns an in-memory SessionStore. Expired
+// sessions are periodically evicted.
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{m: make(map[string]memorySession)}
+}
+
+type memorySessionStore struct {
+	mu    sync.Mutex
+	m     map[string]memorySession
+	sweep time.Time
+}
+
+type memorySession struct {
+	data    map[string]string
+	expires time.Time
+}
+
+func (s *memorySessionStore) Load(id string) (map[string]string, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.sweep) > time.Minute {
+		for k, v := range s.m {
+			if now.After(v.expires) {
+				delete(s.m, k)
+			}
+		}
+		s.sweep = now
+	}
+	v, ok := s.m[id]
+	if !ok || now.After(v.expires) {
+		return nil, nil
+	}
+	data := make(map[string]string, len(v.data))
+	for k, v := range v.data {
+		data[k] = v
+	}
+	return data, nil
+}
+
+func (s *memorySessionStore) Save(id string, data map[string]string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[id] = memorySession{data: data, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *memorySessionStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, id)
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// VerifySignature returns a middleware that verifies the HMAC signature of
+// request bodies, as sent by webhooks of services like GitHub. The HMAC of
+// the raw body is computed with secret and the hash function h, like
+// sha256.New, and compared in constant time against the hex digest in the
+// named header. The digest may be prefixed by the name of the algorithm,
+// like "X-Hub-Signature-256: sha256=<digest>".
+//
+// Requests with a missing or invalid signature are replied with 401
+// Unauthorized. Bodies are read before they're verified, so they're
+// limited to max bytes, or to MaxBodyBytes if smaller, and larger ones are
+// replied with 413 Request Entity Too Large. The limit should fit the
+// largest payload of the webhook, like 25MB for GitHub. The body is read
+// with RawBody, so handlers can still read it. VerifySignature panics if
+// max is not positive.
+//
+// Usage:
+//
+//	verify := httpxtra.VerifySignature("X-Hub-Signature-256",
+//		[]byte(os.Getenv("WEBHOOK_SECRET")), sha256.New, 25<<20)
+//	mux.Handle("^/webhook$", verify(WebhookHandler))
+func VerifySignature(header string, secret []byte, h func() hash.Hash, max int64) Middleware {
+	if max <= 0 {
+		panic("httpxtra: invalid VerifySignature body limit")
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sig := r.Header.Get(header)
+			if i := strings.IndexByte(sig, '='); i >= 0 {
+				sig = sig[i+1:]
+			}
+			want, err := hex.DecodeString(strings.TrimSpace(sig))
+			if err != nil || len(want) == 0 {
+				Error(w, r, http.StatusUnauthorized)
+				return
+			}
+			if r.ContentLength > max {
+				Error(w, r, http.StatusRequestEntityTooLarge)
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				r2 := new(http.Request)
+				*r2 = *r
+				r2.Body = http.MaxBytesReader(w, r.Body, max)
+				r = r2
+			}
+			b, err := RawBody(r)
+			if err == ErrBodyTooLarge {
+				Error(w, r, http.StatusRequestEntityTooLarge)
+				return
+			} else if err != nil {
+				Error(w, r, http.StatusBadRequest)
+				return
+			}
+			mac := hmac.New(h, secret)
+			mac.Write(b)
+			if !hmac.Equal(mac.Sum(nil), want) {
+				Error(w, r, http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
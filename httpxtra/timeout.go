@@ -0,0 +1,134 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timeout returns a middleware that replies with 503 Service Unavailable
+// to requests whose handler doesn't start responding within d. The request
+// context is canceled at that point, so handlers can stop their work, and
+// anything they write afterwards is discarded with http.ErrHandlerTimeout.
+//
+// Handlers that have started responding, like streams, are not affected
+// and may run past d. Unlike http.TimeoutHandler, responses are not
+// buffered, and flushing is supported. Hijacking is not.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+			tw := &timeoutWriter{w: w, h: w.Header().Clone()}
+			expired := make(chan struct{})
+			t := time.AfterFunc(d, func() {
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				if !tw.wrote {
+					tw.timedOut = true
+					cancel()
+					close(expired)
+				}
+			})
+			defer t.Stop()
+			done := make(chan struct{})
+			panicc := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicc <- p
+						return
+					}
+					close(done)
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+			}()
+			select {
+			case p := <-panicc:
+				panic(p)
+			case <-done:
+			case <-expired:
+				Error(w, r, http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// timeoutWriter is the http.ResponseWriter of handlers called by Timeout.
+// Handlers get their own copy of the headers, which is sent when they
+// start responding, so they don't race with the 503 reply.
+type timeoutWriter struct {
+	w        http.ResponseWriter
+	h        http.Header
+	mu       sync.Mutex
+	wrote    bool // whether the handler started responding
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+// start sends the headers of the handler, if not sent yet.
+func (tw *timeoutWriter) start() {
+	if tw.wrote {
+		return
+	}
+	tw.wrote = true
+	h := tw.w.Header()
+	for k := range h {
+		if _, ok := tw.h[k]; !ok {
+			delete(h, k)
+		}
+	}
+	for k, v := range tw.h {
+		h[k] = v
+	}
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.start()
+	tw.w.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.start()
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.start()
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *timeoutWriter) Written() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.wrote || tw.timedOut
+}
+
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
@@ -0,0 +1,21 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import "net/http"
+
+// ClientCertCN returns the common name of the client certificate of
+// requests made over TLS, or an empty string. The rest of the connection
+// state is available in r.TLS.
+//
+// Client certificates are only verified by servers with a TLSConfig whose
+// ClientAuth is tls.VerifyClientCertIfGiven or RequireAndVerifyClientCert.
+// With other settings, the name must not be trusted for authorization.
+func ClientCertCN(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	return r.TLS.PeerCertificates[0].Subject.CommonName
+}
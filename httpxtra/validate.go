@@ -0,0 +1,216 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package httpxtra
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes a field that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is the error returned by Validate, listing every field
+// that failed validation.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	s := make([]string, len(e))
+	for n, fe := range e {
+		s[n] = fe.Field + " " + fe.Message
+	}
+	return strings.Join(s, "; ")
+}
+
+// hostnameRE matches DNS host names, as in RFC 1123.
+var hostnameRE = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?` +
+	`(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*\.?$`)
+
+// Validate checks the fields of the struct pointed to by v against the
+// rules in their "validate" tag, separated by commas, and returns
+// ValidationErrors listing every field that failed, or nil. Fields of
+// nested structs are validated too. The rules are:
+//
+//	required  the value must not be the zero value
+//	min=N     strings and slices must have at least N characters or
+//	          elements, and numbers must be at least N
+//	max=N     like min, for the maximum
+//	email     an email address, like "user@example.com"
+//	ip        an IPv4 or IPv6 address, and ipv4 or ipv6 for either one
+//	hostname  a DNS host name, like "example.com"
+//
+// Rules other than required are not checked for empty values. Fields are
+// named in errors after their "form" or "json" tag, or their name.
+// Unknown rules cause a panic.
+//
+// Example:
+//
+//	type Lookup struct {
+//		Addr   string `form:"addr" validate:"required,ip"`
+//		Format string `form:"format" validate:"max=4"`
+//	}
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	validateStruct(rv, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// BindValid decodes the request body into v like Bind, and validates it
+// like Validate. Handlers usually reply to ValidationErrors with 422
+// Unprocessable Entity:
+//
+//	if err := httpxtra.BindValid(r, &v); err != nil {
+//		if errs, ok := err.(httpxtra.ValidationErrors); ok {
+//			httpxtra.WriteJSON(w, r, http.StatusUnprocessableEntity,
+//				map[string]interface{}{"errors": errs})
+//			return
+//		}
+//		...
+//	}
+func BindValid(r *http.Request, v interface{}) error {
+	if err := Bind(r, v); err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+func validateStruct(sv reflect.Value, prefix string, errs *ValidationErrors) {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		fv := sv.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue // unexported
+		}
+		name := fieldName(sf)
+		if sf.Anonymous {
+			name = ""
+		}
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			for _, rule := range strings.Split(tag, ",") {
+				if msg := checkRule(fv, rule); msg != "" {
+					*errs = append(*errs, FieldError{
+						Field:   prefix + name,
+						Rule:    rule,
+						Message: msg,
+					})
+					break
+				}
+			}
+		}
+		if fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			p := prefix
+			if name != "" {
+				p += name + "."
+			}
+			validateStruct(fv, p, errs)
+		}
+	}
+}
+
+// fieldName returns the name of the field in its form or json tag, or
+// its Go name.
+func fieldName(sf reflect.StructField) string {
+	for _, key := range []string{"form", "json"} {
+		name := strings.Split(sf.Tag.Get(key), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// checkRule returns the message for v failing rule, or an empty string.
+func checkRule(v reflect.Value, rule string) string {
+	name, arg := rule, ""
+	if n := strings.IndexByte(rule, '='); n >= 0 {
+		name, arg = rule[:n], rule[n+1:]
+	}
+	if name == "required" {
+		if v.IsZero() {
+			return "is required"
+		}
+		return ""
+	}
+	if v.IsZero() {
+		return ""
+	}
+	switch name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic("httpxtra: invalid validation rule " + rule)
+		}
+		n, unit := size(v)
+		if name == "min" && n < limit {
+			return "must be at least " + arg + unit
+		}
+		if name == "max" && n > limit {
+			return "must be at most " + arg + unit
+		}
+	case "email":
+		s := v.String()
+		if a, err := mail.ParseAddress(s); err != nil || a.Address != s {
+			return "must be a valid email address"
+		}
+	case "ip", "ipv4", "ipv6":
+		ip := net.ParseIP(v.String())
+		if ip == nil ||
+			name == "ipv4" && ip.To4() == nil ||
+			name == "ipv6" && ip.To4() != nil {
+			return "must be a valid " + strings.ToUpper(name[:2]) +
+				name[2:] + " address"
+		}
+	case "hostname":
+		if s := v.String(); len(s) > 254 || !hostnameRE.MatchString(s) {
+			return "must be a valid host name"
+		}
+	default:
+		panic("httpxtra: unknown validation rule " + rule)
+	}
+	return ""
+}
+
+// size returns the length of strings, slices and maps, or the value of
+// numbers, and the unit for messages.
+func size(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters long"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	}
+	panic(fmt.Sprintf("httpxtra: can't validate the size of %s", v.Type()))
+}
@@ -0,0 +1,89 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package remux
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Group registers handlers on a ServeMux under a common path prefix,
+// wrapped by a common set of middleware.
+//
+// Example:
+//
+//	api := remux.NewGroup("/api/v1", AuthMiddleware)
+//	api.HandleFunc("/users$", UsersHandler)           // ^/api/v1/users$
+//	admin := api.Group("/admin", AdminOnly)
+//	admin.HandleFunc("/reload$", ReloadHandler)       // ^/api/v1/admin/reload$
+type Group struct {
+	mux    *ServeMux
+	prefix string
+	mw     []func(http.Handler) http.Handler
+}
+
+// Group returns a route group for the given path prefix and middleware.
+//
+// The prefix is a literal path, not a regular expression. Patterns
+// registered in the group are anchored at the beginning of the prefix,
+// with or without a leading "^". Middleware are applied outermost first.
+func (mux *ServeMux) Group(prefix string, mw ...func(http.Handler) http.Handler) *Group {
+	return &Group{mux: mux, prefix: prefix, mw: mw}
+}
+
+// NewGroup returns a route group of the DefaultServeMux.
+func NewGroup(prefix string, mw ...func(http.Handler) http.Handler) *Group {
+	return DefaultServeMux.Group(prefix, mw...)
+}
+
+// Group returns a nested route group. Its prefix is appended to the prefix
+// of g, and its middleware run after the middleware of g.
+func (g *Group) Group(prefix string, mw ...func(http.Handler) http.Handler) *Group {
+	m := make([]func(http.Handler) http.Handler, 0, len(g.mw)+len(mw))
+	m = append(append(m, g.mw...), mw...)
+	return &Group{mux: g.mux, prefix: g.prefix + prefix, mw: m}
+}
+
+// pattern returns the full pattern for p in the group.
+func (g *Group) pattern(p string) string {
+	return "^" + regexp.QuoteMeta(g.prefix) + strings.TrimPrefix(p, "^")
+}
+
+// wrap applies the group middleware to h.
+func (g *Group) wrap(h http.Handler) http.Handler {
+	for i := len(g.mw) - 1; i >= 0; i-- {
+		h = g.mw[i](h)
+	}
+	return h
+}
+
+// Handle registers the handler for the given pattern in the group.
+func (g *Group) Handle(pattern string, handler http.Handler) {
+	g.HandleMethod("", pattern, handler)
+}
+
+// HandleFunc registers the handler function for the given pattern in the
+// group.
+func (g *Group) HandleFunc(pattern string,
+	handler func(http.ResponseWriter, *http.Request)) {
+	g.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// HandleMethod registers the handler for the given method and pattern in
+// the group.
+func (g *Group) HandleMethod(method, pattern string, handler http.Handler) {
+	if handler == nil {
+		panic("http: nil handler")
+	}
+	g.mux.HandleMethod(method, g.pattern(pattern), g.wrap(handler))
+}
+
+// HandleMethodFunc registers the handler function for the given method
+// and pattern in the group.
+func (g *Group) HandleMethodFunc(method, pattern string,
+	handler func(http.ResponseWriter, *http.Request)) {
+	g.HandleMethod(method, pattern, http.HandlerFunc(handler))
+}
@@ -0,0 +1,87 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package remux
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Hosts is an http.Handler that dispatches requests to handlers by the
+// host name of the request, without the port number, so one server can
+// serve multiple sites, like api.example.com and www.example.com, each
+// with its own ServeMux.
+//
+// Keys are lower case host names, or wildcards like "*.example.com" for
+// any subdomain, at any depth, of example.com. Exact names take precedence
+// over wildcards, and longer wildcards over shorter ones. The handler of
+// "*", if any, serves requests for all other hosts, which otherwise get
+// 404 Not Found.
+//
+// The part of the host name matched by a wildcard is available to
+// handlers with Subdomain, for example to select a tenant.
+//
+// Example:
+//
+//	hosts := remux.Hosts{
+//		"api.example.com": apiMux,
+//		"*.example.com":   tenantMux,
+//		"*":               wwwMux,
+//	}
+//	http.ListenAndServe(":8080", hosts)
+type Hosts map[string]http.Handler
+
+func (hosts Hosts) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, sub := hosts.handler(HostName(r.Host))
+	if h == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if sub != "" {
+		r = r.WithContext(context.WithValue(r.Context(), subdomainKey, sub))
+	}
+	h.ServeHTTP(w, r)
+}
+
+// handler returns the handler for the host name, or nil, and the part of
+// the name matched by a wildcard.
+func (hosts Hosts) handler(name string) (http.Handler, string) {
+	if h := hosts[name]; h != nil {
+		return h, ""
+	}
+	for n := 0; ; n++ {
+		i := strings.IndexByte(name[n:], '.')
+		if i < 0 {
+			break
+		}
+		n += i
+		if h := hosts["*"+name[n:]]; h != nil {
+			return h, name[:n]
+		}
+	}
+	return hosts["*"], ""
+}
+
+// Subdomain returns the part of the host name matched by the wildcard of
+// Hosts, like "acme" for "acme.example.com" and "*.example.com", or an
+// empty string. Subdomains are in lower case.
+func Subdomain(r *http.Request) string {
+	sub, _ := r.Context().Value(subdomainKey).(string)
+	return sub
+}
+
+// HostName returns the host name of the Host header v, without the port
+// number, the brackets of IPv6 addresses and the trailing dot, in lower
+// case, like "example.com" for "Example.com.:8080". It's how Hosts
+// matches host names.
+func HostName(v string) string {
+	if host, _, err := net.SplitHostPort(v); err == nil {
+		v = host
+	}
+	v = strings.Trim(v, "[]")
+	return strings.ToLower(strings.TrimSuffix(v, "."))
+}
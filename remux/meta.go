@@ -0,0 +1,40 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package remux
+
+import "net/http"
+
+// SetMeta attaches metadata to the registered pattern, like
+// {"auth": true} or a rate limit tier. It's available to handlers and
+// middleware of requests matching the pattern via RouteMeta, so generic
+// middleware can behave differently per route. It panics if the pattern
+// is not registered.
+func (mux *ServeMux) SetMeta(pattern string, meta map[string]interface{}) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	e := mux.m[pattern]
+	if e == nil {
+		panic("remux: unknown pattern " + pattern)
+	}
+	e.meta = meta
+}
+
+// SetMeta attaches metadata to the registered pattern of the group. See
+// ServeMux.SetMeta.
+func (g *Group) SetMeta(pattern string, meta map[string]interface{}) {
+	g.mux.SetMeta(g.pattern(pattern), meta)
+}
+
+// RouteMeta returns the metadata of the pattern that matched the URL, or
+// nil if there's none. The map is shared by all requests and must not be
+// modified.
+//
+// Middleware of the ServeMux itself, like that of groups, can read it.
+// Middleware wrapping the ServeMux runs before routing, and only sees it
+// after ServeHTTP returns, with Track.
+func RouteMeta(r *http.Request) map[string]interface{} {
+	return getVar(r).meta
+}
@@ -0,0 +1,86 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package remux
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Mount registers the handler for the path prefix and everything under
+// it. The prefix is a literal path, not a regular expression, and it's
+// stripped from the URL path before calling the handler, like
+// http.StripPrefix, so handlers of subtrees can be mounted anywhere.
+//
+// The prefix is kept in the route of mounted ServeMux: Route returns
+// "^/api/users$" for the pattern "^/users$" of a ServeMux mounted at
+// "/api", so logs and metrics tell apart routes mounted at different
+// prefixes.
+//
+// Example:
+//
+//	mux.Mount("/static", http.FileServer(http.Dir("./static")))
+//	mux.Mount("/api", apiMux) // apiMux gets /users for /api/users
+func (mux *ServeMux) Mount(prefix string, handler http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mux.Handle(mountPattern(prefix), stripPrefix(prefix, handler))
+}
+
+// Mount registers the handler for the DefaultServeMux.
+func Mount(prefix string, handler http.Handler) {
+	DefaultServeMux.Mount(prefix, handler)
+}
+
+// Mount registers the handler for the path prefix and everything under
+// it, in the group. Both the group prefix and the given prefix are
+// stripped from the URL path before calling the handler.
+func (g *Group) Mount(prefix string, handler http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	g.mux.Handle(mountPattern(g.prefix+prefix),
+		g.wrap(stripPrefix(g.prefix+prefix, handler)))
+}
+
+// CatchAll returns a pattern that matches the path prefix and everything
+// under it, and captures the rest of the path, which may contain slashes,
+// in the group named "path". The prefix is a literal path, not a regular
+// expression. For example, CatchAll("/files/") matches "/files/a/b/c",
+// with "a/b/c" in Vars(r)[0] and Params(r)["path"], and "/files/" with an
+// empty path, but not "/files" or "/filesystem".
+//
+// Unlike Mount, the URL path is not modified.
+func CatchAll(prefix string) string {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return "^" + regexp.QuoteMeta(prefix) + "(?P<path>.*)$"
+}
+
+// mountPattern returns the pattern that matches prefix and all paths
+// under it.
+func mountPattern(prefix string) string {
+	return "^" + regexp.QuoteMeta(prefix) + "(?:/|$)"
+}
+
+// stripPrefix returns a handler that removes prefix from the URL path of
+// requests before calling h. An empty path becomes "/". The prefix is
+// recorded in the route match, for the routes of nested muxes.
+func stripPrefix(prefix string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rm, ok := r.Context().Value(matchKey).(*routeMatch); ok {
+			rm.mount += prefix
+		}
+		u := *r.URL
+		u.Path = strings.TrimPrefix(r.URL.Path, prefix)
+		if u.Path == "" {
+			u.Path = "/"
+		}
+		u.RawPath = ""
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = &u
+		h.ServeHTTP(w, r2)
+	})
+}
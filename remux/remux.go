@@ -31,9 +31,14 @@
 package remux
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"net/url"
 	"path"
 	"regexp"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -44,6 +49,16 @@ import (
 //
 // Patterns are regular expressions, like "^/$". On routing decision,
 // the handler of the first regex that match against URL.Path is executed.
+// Patterns are compiled once, when registered, and indexed by their literal
+// prefix, like "/static/" in "^/static/(.*)$", so requests only evaluate
+// the patterns that can match their path. Matching is case-sensitive,
+// unless the pattern sets the i flag, like "(?i)^/static/" or
+// "^/api/(?i)users$", where only the part after the flag is affected.
+//
+// Handlers may be restricted to a given HTTP method with HandleMethod.
+// When the URL matches one or more patterns but none of them accepts the
+// request method, ServeMux replies with 405 Method Not Allowed and an
+// Allow header listing the methods registered for the URL.
 //
 // Patterns may optionally begin with a host name, restricting matches to
 // URLs on that host only.  Host-specific patterns take precedence over
@@ -56,53 +71,172 @@ import (
 // equivalent .- and ..-free URL.
 type ServeMux struct {
 	mu sync.RWMutex
-	m  map[*regexp.Regexp]muxEntry
+	m  map[string]*muxEntry
+	t  trie // patterns indexed by literal prefix
+
+	static map[string]*muxEntry // patterns of a single path, by path
+
+	// hosts is set when any pattern may match host names, that is, it
+	// doesn't begin with a slash.
+	hosts bool
+
+	names map[string]*urlTemplate // named routes, see Name
+
+	// RedirectTrailingSlash enables redirection of URLs that don't match
+	// any pattern, but would with a trailing slash added or removed. GET
+	// and HEAD requests are redirected with 301 Moved Permanently, and
+	// other methods with 308 Permanent Redirect, which preserves them.
+	// The query string is kept.
+	RedirectTrailingSlash bool
+
+	// AutoHead enables serving HEAD requests with the GET handler of
+	// patterns without a HEAD handler. The response body is discarded by
+	// net/http, while headers like Content-Length are kept.
+	AutoHead bool
+
+	// AutoOptions enables replying to OPTIONS requests for patterns
+	// without an OPTIONS handler with 204 No Content and an Allow header
+	// listing the methods registered for the URL.
+	AutoOptions bool
+
+	// NotFoundHandler, if set, replies to requests that don't match any
+	// pattern, instead of http.NotFoundHandler. It may serve a custom page,
+	// or the index.html of single page applications.
+	NotFoundHandler http.Handler
+
+	// MethodNotAllowedHandler, if set, replies to requests that match
+	// patterns that don't accept their method. The Allow header is set
+	// before it's called.
+	MethodNotAllowedHandler http.Handler
 }
 
 type muxEntry struct {
-	explicit bool
-	h        http.Handler
+	n       int // order of registration
+	re      *regexp.Regexp
+	h       http.Handler            // handler for any method
+	methods map[string]http.Handler // method-specific handlers
+	meta    map[string]interface{}  // see SetMeta
 }
 
-var vdata map[*http.Request][]string
-var vlock sync.RWMutex
+// routeMatch holds the result of the pattern regexp executed on URL.Path.
+type routeMatch struct {
+	route  string // pattern that matched
+	vars   []string
+	params map[string]string
+	meta   map[string]interface{}
+	mount  string // path prefix stripped by Mount
+}
+
+type contextKey int
+
+const (
+	matchKey contextKey = iota
+	subdomainKey
+)
 
-func setVar(r *http.Request, m []string) {
-	if vdata == nil {
-		vdata = make(map[*http.Request][]string)
+// getVar returns the route match stored in the request context.
+func getVar(r *http.Request) routeMatch {
+	if m, ok := r.Context().Value(matchKey).(*routeMatch); ok {
+		return *m
 	}
-	vlock.Lock()
-	defer vlock.Unlock()
-	vdata[r] = m
+	return routeMatch{}
 }
 
-func delVar(r *http.Request) {
-	if vdata == nil {
-		return
-	}
-	vlock.RLock()
-	_, exists := vdata[r]
-	vlock.RUnlock()
-	if exists {
-		vlock.Lock()
-		defer vlock.Unlock()
-		delete(vdata, r)
+// Track returns a shallow copy of r that records the routing result of
+// the ServeMux serving it. Then Vars, Params and Route are also available
+// to the caller of ServeHTTP after it returns, for example for logging.
+//
+// When a ServeMux is the handler of a pattern of another, the match of
+// the nested one is added to that of the outer one: Route is the nested
+// pattern, prefixed with the path of Mount if mounted, Vars are appended,
+// and Params and RouteMeta of the nested pattern take precedence.
+func Track(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(matchKey).(*routeMatch); ok {
+		return r
 	}
+	return r.WithContext(context.WithValue(r.Context(), matchKey, &routeMatch{}))
 }
 
 // Vars returns the result of the regex execution on the URL pattern.
+//
+// Vars and Params are stored in the request context, and are still
+// available to requests derived with r.WithContext, for example by
+// middleware.
 func Vars(r *http.Request) []string {
-	if vdata == nil {
-		return nil
+	return getVar(r).vars
+}
+
+// Params returns the named groups of the regex execution on the URL
+// pattern, keyed by group name. For example, the pattern
+// "^/(?P<format>csv|json|xml)/(?P<addr>.*)$" populates "format" and "addr".
+// Unnamed groups are only available in Vars.
+func Params(r *http.Request) map[string]string {
+	return getVar(r).params
+}
+
+// Route returns the pattern that matched the URL, like "^/(csv|json)/(.*)$",
+// or an empty string if no pattern matched. It's useful for grouping
+// requests by route in logs and metrics.
+func Route(r *http.Request) string {
+	return getVar(r).route
+}
+
+// WithVars returns a shallow copy of r for which Vars and Params return
+// the given values, as if r had been routed by a ServeMux. It's meant for
+// calling handlers directly, for example in tests with httptest.
+func WithVars(r *http.Request, vars []string, params map[string]string) *http.Request {
+	m := &routeMatch{vars: vars, params: params}
+	return r.WithContext(context.WithValue(r.Context(), matchKey, m))
+}
+
+func newRouteMatch(e *muxEntry, m []string) routeMatch {
+	rm := routeMatch{
+		route: e.re.String(),
+		meta:  e.meta,
+	}
+	if m != nil {
+		rm.vars = m[1:] // m[0] is URL.Path thus not needed
+	}
+	for n, name := range e.re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if rm.params == nil {
+			rm.params = make(map[string]string)
+		}
+		rm.params[name] = m[n]
+	}
+	return rm
+}
+
+// nest returns the match of a ServeMux nested in the one that produced
+// rm, to be used by both. The route is that of the nested mux, with the
+// path prefix stripped by Mount put back, while vars are appended to those
+// of rm, and params and metadata take precedence over those of rm.
+func (rm *routeMatch) nest(m routeMatch) routeMatch {
+	if m.mount = rm.mount; m.mount != "" && strings.HasPrefix(m.route, "^") {
+		m.route = "^" + regexp.QuoteMeta(m.mount) + m.route[1:]
+	}
+	m.vars = append(rm.vars[:len(rm.vars):len(rm.vars)], m.vars...)
+	if len(rm.params) > 0 {
+		params := make(map[string]string, len(rm.params)+len(m.params))
+		for k, v := range rm.params {
+			params[k] = v
+		}
+		for k, v := range m.params {
+			params[k] = v
+		}
+		m.params = params
+	}
+	if m.meta == nil {
+		m.meta = rm.meta
 	}
-	vlock.RLock()
-	defer vlock.RUnlock()
-	return vdata[r]
+	return m
 }
 
 // NewServeMux allocates and returns a new ServeMux.
 func NewServeMux() *ServeMux {
-	return &ServeMux{m: make(map[*regexp.Regexp]muxEntry)}
+	return &ServeMux{m: make(map[string]*muxEntry)}
 }
 
 // DefaultServeMux is the default ServeMux used by Serve.
@@ -125,34 +259,190 @@ func cleanPath(p string) string {
 	return np
 }
 
-// Find a handler on a handler map given a path string
-// Most-specific (longest) pattern wins
-func (mux *ServeMux) match(path string) ([]string, http.Handler) {
-	for k, v := range mux.m {
-		m := k.FindStringSubmatch(path)
-		if len(m) >= 1 {
-			return m[1:], v.h // m[0] is URL.Path thus not needed
+// Find a handler on a handler map given a method and path string.
+// Patterns are tried in order of registration, skipping those that can't
+// match because of their literal prefix. Static patterns, which match a
+// single path, are found without evaluating regular expressions. If the
+// path matches patterns that don't accept the method, their methods are
+// returned in allow.
+func (mux *ServeMux) match(method, path string) (rm routeMatch, h http.Handler, allow []string) {
+	static := mux.static[path]
+	for _, e := range mux.t.lookup(path) {
+		if static != nil && static.n < e.n {
+			if h, allow = mux.accept(static, method, allow); h != nil {
+				return newRouteMatch(static, nil), h, nil
+			}
+			static = nil
+		}
+		// Patterns without groups are matched without allocations.
+		var m []string
+		if e.re.NumSubexp() == 0 {
+			if !e.re.MatchString(path) {
+				continue
+			}
+		} else if m = e.re.FindStringSubmatch(path); m == nil {
+			continue
+		}
+		if h, allow = mux.accept(e, method, allow); h != nil {
+			return newRouteMatch(e, m), h, nil
+		}
+	}
+	if static != nil {
+		if h, allow = mux.accept(static, method, allow); h != nil {
+			return newRouteMatch(static, nil), h, nil
 		}
 	}
-	return nil, nil
+	return rm, nil, allow
 }
 
-// handler returns the handler to use for the request r.
-func (mux *ServeMux) handler(r *http.Request) http.Handler {
+// accept returns the handler of the matching entry e for method, or nil
+// and the methods e accepts appended to allow.
+func (mux *ServeMux) accept(e *muxEntry, method string, allow []string) (http.Handler, []string) {
+	h := e.methods[method]
+	if h == nil {
+		h = e.h
+	}
+	if h == nil && method == "HEAD" && mux.AutoHead {
+		h = e.methods["GET"]
+	}
+	if h != nil {
+		return h, allow
+	}
+	for k := range e.methods {
+		allow = append(allow, k)
+	}
+	if mux.AutoHead && e.methods["GET"] != nil {
+		allow = append(allow, "HEAD")
+	}
+	if mux.AutoOptions {
+		allow = append(allow, "OPTIONS")
+	}
+	return nil, allow
+}
+
+// allowHeader returns the sorted list of unique methods in allow, for the
+// Allow header.
+func allowHeader(allow []string) string {
+	sort.Strings(allow)
+	methods := allow[:0]
+	for n, v := range allow {
+		if n == 0 || v != allow[n-1] {
+			methods = append(methods, v)
+		}
+	}
+	return strings.Join(methods, ", ")
+}
+
+// methodNotAllowed returns a handler that replies with 405 and the
+// list of allowed methods in the Allow header, or calls the mux
+// MethodNotAllowedHandler after setting the header.
+func (mux *ServeMux) methodNotAllowed(allow []string) http.Handler {
+	methods := allowHeader(allow)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", methods)
+		if mux.MethodNotAllowedHandler != nil {
+			mux.MethodNotAllowedHandler.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed),
+			http.StatusMethodNotAllowed)
+	})
+}
+
+// options returns a handler that replies to OPTIONS requests with 204 and
+// the list of allowed methods in the Allow header.
+func options(allow []string) http.Handler {
+	methods := allowHeader(allow)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", methods)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// handler returns the handler to use for the request r, and the result
+// of the pattern regexp executed on URL.Path.
+func (mux *ServeMux) handler(r *http.Request) (http.Handler, routeMatch) {
 	mux.mu.RLock()
 	defer mux.mu.RUnlock()
 
 	// Host-specific pattern takes precedence over generic ones
-	m, h := mux.match(r.Host + r.URL.Path)
+	var (
+		m     routeMatch
+		h     http.Handler
+		allow []string
+	)
+	if mux.hosts {
+		m, h, allow = mux.match(r.Method, r.Host+r.URL.Path)
+	}
 	if h == nil {
-		m, h = mux.match(r.URL.Path)
+		var a []string
+		m, h, a = mux.match(r.Method, r.URL.Path)
+		allow = append(allow, a...)
 	}
 	if h == nil {
-		h = http.NotFoundHandler()
+		if len(allow) > 0 && r.Method == "OPTIONS" && mux.AutoOptions {
+			h = options(allow)
+		} else if len(allow) > 0 {
+			h = mux.methodNotAllowed(allow)
+		} else if p, ok := mux.trailingSlash(r); ok {
+			h = redirectPath(p)
+		} else if mux.NotFoundHandler != nil {
+			h = mux.NotFoundHandler
+		} else {
+			h = http.NotFoundHandler()
+		}
+	}
+	return h, m
+}
+
+// Match reports whether a pattern matches the URL of r and accepts its
+// method, so the request would be served by a registered handler rather
+// than get 404 Not Found or 405 Method Not Allowed. Automatic replies to
+// HEAD requests count, while those to OPTIONS requests don't.
+func (mux *ServeMux) Match(r *http.Request) bool {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	if mux.hosts {
+		if _, h, _ := mux.match(r.Method, r.Host+r.URL.Path); h != nil {
+			return true
+		}
 	}
-	// Vars hold the result of the pattern regexp executed on URL.Path
-	setVar(r, m)
-	return h
+	_, h, _ := mux.match(r.Method, r.URL.Path)
+	return h != nil
+}
+
+// trailingSlash returns the URL path of r with its trailing slash added
+// or removed, if RedirectTrailingSlash is set and a pattern matches it.
+func (mux *ServeMux) trailingSlash(r *http.Request) (string, bool) {
+	p := r.URL.Path
+	if !mux.RedirectTrailingSlash || p == "/" || p == "" {
+		return "", false
+	}
+	if strings.HasSuffix(p, "/") {
+		p = p[:len(p)-1]
+	} else {
+		p += "/"
+	}
+	for _, path := range []string{r.Host + p, p} {
+		if _, h, allow := mux.match(r.Method, path); h != nil || allow != nil {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// redirectPath returns a handler that redirects requests to the given
+// path, keeping their query string.
+func redirectPath(p string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := url.URL{Path: p, RawQuery: r.URL.RawQuery}
+		code := http.StatusMovedPermanently
+		if r.Method != "GET" && r.Method != "HEAD" {
+			code = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, u.String(), code)
+	})
 }
 
 // ServeHTTP dispatches the request to the handler whose
@@ -166,13 +456,35 @@ func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	mux.handler(r).ServeHTTP(w, r)
-	delVar(r)
+	h, m := mux.handler(r)
+	// Vars and Params hold the result of the pattern regexp executed
+	// on URL.Path
+	if rm, ok := r.Context().Value(matchKey).(*routeMatch); ok {
+		if rm.route == "" {
+			*rm = m // see Track
+		} else if m.route != "" {
+			*rm = rm.nest(m) // mux nested in another
+		}
+		h.ServeHTTP(w, r)
+		return
+	}
+	rm := new(routeMatch)
+	*rm = m
+	h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), matchKey, rm)))
 }
 
 // Handle registers the handler for the given pattern.
 // If a handler already exists for pattern, Handle panics.
 func (mux *ServeMux) Handle(pattern string, handler http.Handler) {
+	mux.HandleMethod("", pattern, handler)
+}
+
+// HandleMethod registers the handler for the given method and pattern.
+// An empty method registers the handler for any method, like Handle.
+// If a handler already exists for method and pattern, HandleMethod panics.
+// It also panics if the pattern is not a valid regular expression, naming
+// the pattern, so misconfigured routes fail when the server starts.
+func (mux *ServeMux) HandleMethod(method, pattern string, handler http.Handler) {
 	mux.mu.Lock()
 	defer mux.mu.Unlock()
 
@@ -182,22 +494,44 @@ func (mux *ServeMux) Handle(pattern string, handler http.Handler) {
 	if handler == nil {
 		panic("http: nil handler")
 	}
-	pattern_re := regexp.MustCompile(pattern)
-	if mux.m[pattern_re].explicit {
-		panic("http: multiple registrations for " + pattern)
+	e := mux.m[pattern]
+	if e == nil {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			panic(fmt.Sprintf("remux: invalid pattern %q: %v", pattern, err))
+		}
+		e = &muxEntry{n: len(mux.m), re: re}
+		mux.m[pattern] = e
+		prefix, static := literalPrefix(pattern)
+		if static && mux.static[prefix] == nil {
+			if mux.static == nil {
+				mux.static = make(map[string]*muxEntry)
+			}
+			mux.static[prefix] = e
+		} else {
+			// Later static patterns for the same path, like `^/a\z`
+			// after `^/a$`, are ordered with the others by match.
+			mux.t.insert(prefix, e)
+		}
+		if !strings.HasPrefix(prefix, "/") {
+			mux.hosts = true
+		}
 	}
-
-	mux.m[pattern_re] = muxEntry{explicit: true, h: handler}
-
-	// Helpful behavior:
-	// If pattern is /tree/, insert an implicit permanent redirect for /tree.
-	// It can be overridden by an explicit registration.
-	/*
-		n := len(pattern)
-		if n > 0 && pattern[n-1] == '/' && !mux.m[pattern[0:n-1]].explicit {
-			mux.m[pattern[0:n-1]] = muxEntry{h: RedirectHandler(pattern, StatusMovedPermanently)}
+	if method == "" {
+		if e.h != nil {
+			panic("http: multiple registrations for " + pattern)
 		}
-	*/
+		e.h = handler
+	} else {
+		if e.methods == nil {
+			e.methods = make(map[string]http.Handler)
+		}
+		if e.methods[method] != nil {
+			panic("http: multiple registrations for " +
+				method + " " + pattern)
+		}
+		e.methods[method] = handler
+	}
 }
 
 // HandleFunc registers the handler function for the given pattern.
@@ -206,9 +540,22 @@ func (mux *ServeMux) HandleFunc(pattern string,
 	mux.Handle(pattern, http.HandlerFunc(handler))
 }
 
+// HandleMethodFunc registers the handler function for the given method
+// and pattern.
+func (mux *ServeMux) HandleMethodFunc(method, pattern string,
+	handler func(http.ResponseWriter, *http.Request)) {
+	mux.HandleMethod(method, pattern, http.HandlerFunc(handler))
+}
+
 // HandleFunc registers the handler function for the given pattern
 // in the DefaultServeMux.
 // The documentation for ServeMux explains how patterns are matched.
 func HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
 	DefaultServeMux.HandleFunc(pattern, handler)
 }
+
+// HandleMethodFunc registers the handler function for the given method
+// and pattern in the DefaultServeMux.
+func HandleMethodFunc(method, pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	DefaultServeMux.HandleMethodFunc(method, pattern, handler)
+}
@@ -0,0 +1,268 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package remux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// linearMatch finds the first of entries matching path by evaluating
+// all patterns in order of registration, as the ServeMux did before
+// patterns were indexed by literal prefix.
+func linearMatch(entries []*muxEntry, path string) (*muxEntry, []string) {
+	for _, e := range entries {
+		if m := e.re.FindStringSubmatch(path); m != nil {
+			return e, m
+		}
+	}
+	return nil, nil
+}
+
+// newTestMux returns a ServeMux with the patterns, registered in order,
+// and the entries of the patterns.
+func newTestMux(patterns []string) (*ServeMux, []*muxEntry) {
+	mux := NewServeMux()
+	entries := make([]*muxEntry, len(patterns))
+	for i, pattern := range patterns {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {})
+		entries[i] = mux.m[pattern]
+	}
+	return mux, entries
+}
+
+// TestMatchLinear checks that the trie matches the same patterns as a
+// linear scan, with the same vars.
+func TestMatchLinear(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		paths    []string
+	}{
+		{
+			"overlapping prefixes",
+			[]string{"^/users/(.*)$", "^/users/([0-9]+)$", "^/user", "^/u",
+				"^/users/admin$", "^/users/"},
+			[]string{"/users/42", "/users/admin", "/users/", "/users",
+				"/user", "/u", "/x"},
+		},
+		{
+			"unanchored",
+			[]string{"^/a/b$", "/b$", "json", "^/a/(.*)$"},
+			[]string{"/a/b", "/x/b", "/a/json", "/a/c", "/json/x", "/"},
+		},
+		{
+			"case-insensitive",
+			[]string{"(?i)^/About$", "^/about$", "^/(?i:contact)$",
+				"^/CONTACT$"},
+			[]string{"/about", "/ABOUT", "/About", "/contact", "/CONTACT",
+				"/Contact"},
+		},
+		{
+			"alternations at the root",
+			[]string{"^/(csv|json|xml)/(.*)$", "^(/a|/b)$", "^/json/x$",
+				"^/a$", "^/b$", "^/$"},
+			[]string{"/csv/1.2.3.4", "/json/x", "/a", "/b", "/", "/c"},
+		},
+		{
+			"captures",
+			[]string{"^/(?P<format>csv|json)/(?P<addr>.*)$",
+				"^/c/(?P<id>[0-9]+)/([a-z]+)$", "^/d/(x)?(y)?$"},
+			[]string{"/csv/8.8.8.8", "/json/", "/c/7/abc", "/c/7/",
+				"/d/", "/d/y", "/d/xy"},
+		},
+	}
+	for _, tt := range tests {
+		mux, entries := newTestMux(tt.patterns)
+		for _, path := range tt.paths {
+			rm, h, _ := mux.match("GET", path)
+			e, m := linearMatch(entries, path)
+			if e == nil {
+				if h != nil {
+					t.Errorf("%s: %s: matched %q, want no match",
+						tt.name, path, rm.route)
+				}
+				continue
+			}
+			if h == nil || rm.route != e.re.String() {
+				t.Errorf("%s: %s: matched %q, want %q",
+					tt.name, path, rm.route, e.re.String())
+				continue
+			}
+			if fmt.Sprintf("%q", rm.vars) != fmt.Sprintf("%q", m[1:]) {
+				t.Errorf("%s: %s: vars %q, want %q",
+					tt.name, path, rm.vars, m[1:])
+			}
+		}
+	}
+}
+
+// TestMatchStaticOrder checks that static patterns, which are matched
+// without regular expressions, keep their order of registration.
+func TestMatchStaticOrder(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		want     string
+	}{
+		{[]string{"^/.*$", "^/metrics$"}, "/metrics", "^/.*$"},
+		{[]string{"^/metrics$", "^/.*$"}, "/metrics", "^/metrics$"},
+		{[]string{"^/m", "^/metrics$"}, "/metrics", "^/m"},
+		{[]string{"metrics", "^/metrics$"}, "/metrics", "metrics"},
+		{[]string{"^/foo$", `^/foo\z`}, "/foo", "^/foo$"},
+		{[]string{`^/foo\z`, "^/foo$"}, "/foo", `^/foo\z`},
+		{[]string{"^/foo$", "^/.*$", `^/foo\z`}, "/foo", "^/foo$"},
+		{[]string{"^/bar$", "^/.*$", `^/foo\z`, "^/foo$"}, "/foo", "^/.*$"},
+	}
+	for _, tt := range tests {
+		mux, entries := newTestMux(tt.patterns)
+		rm, h, _ := mux.match("GET", tt.path)
+		if h == nil || rm.route != tt.want {
+			t.Errorf("%q: %s matched %q, want %q",
+				tt.patterns, tt.path, rm.route, tt.want)
+		}
+		if e, _ := linearMatch(entries, tt.path); e.re.String() != tt.want {
+			t.Errorf("%q: %s: linear scan matched %q, want %q",
+				tt.patterns, tt.path, e.re.String(), tt.want)
+		}
+	}
+}
+
+// TestRouteNested checks the Route and Vars of requests routed through
+// muxes nested in others, both for their handlers and with Track.
+func TestRouteNested(t *testing.T) {
+	var route string
+	var vars []string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		route, vars = Route(r), Vars(r)
+	}
+	users := NewServeMux()
+	users.HandleFunc(`^/users/([0-9]+)$`, record)
+	api := NewServeMux()
+	api.Mount("/v1", users)
+	hosts := NewServeMux()
+	hosts.HandleFunc(`^/users/([0-9]+)$`, record)
+
+	mux := NewServeMux()
+	mux.Mount("/api", users)
+	mux.Mount("/v2", users)
+	mux.Mount("/deep", api)
+	mux.Group("/g").Mount("/x", users)
+	mux.Handle(`^([a-z]+)\.example\.com/`, hosts)
+	mux.Mount("/files", http.HandlerFunc(record))
+
+	tests := []struct {
+		host, path string
+		route      string
+		vars       []string
+	}{
+		{"", "/api/users/1", `^/api/users/([0-9]+)$`, []string{"1"}},
+		{"", "/v2/users/2", `^/v2/users/([0-9]+)$`, []string{"2"}},
+		{"", "/deep/v1/users/3", `^/deep/v1/users/([0-9]+)$`, []string{"3"}},
+		{"", "/g/x/users/4", `^/g/x/users/([0-9]+)$`, []string{"4"}},
+		{"acme.example.com", "/users/5", `^/users/([0-9]+)$`,
+			[]string{"acme", "5"}},
+		{"", "/files/a", `^/files(?:/|$)`, []string{}},
+	}
+	for _, tt := range tests {
+		route, vars = "", nil
+		r := httptest.NewRequest("GET", tt.path, nil)
+		if tt.host != "" {
+			r.Host = tt.host
+		}
+		r = Track(r)
+		mux.ServeHTTP(httptest.NewRecorder(), r)
+		if route != tt.route || fmt.Sprint(vars) != fmt.Sprint(tt.vars) {
+			t.Errorf("%s%s: handler got %q %q, want %q %q",
+				tt.host, tt.path, route, vars, tt.route, tt.vars)
+		}
+		if Route(r) != tt.route || fmt.Sprint(Vars(r)) != fmt.Sprint(tt.vars) {
+			t.Errorf("%s%s: Track got %q %q, want %q %q", tt.host, tt.path,
+				Route(r), Vars(r), tt.route, tt.vars)
+		}
+	}
+
+	// Paths not matched by the nested mux keep the outer match.
+	r := Track(httptest.NewRequest("GET", "/api/nope", nil))
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+	if want := `^/api(?:/|$)`; Route(r) != want {
+		t.Errorf("/api/nope: Track got %q, want %q", Route(r), want)
+	}
+}
+
+// benchmarkRoutes benchmarks matching path against 500 patterns created
+// from format, with and without the trie.
+func benchmarkRoutes(b *testing.B, format, path string) {
+	patterns := make([]string, 500)
+	for i := range patterns {
+		patterns[i] = fmt.Sprintf(format, i)
+	}
+	mux, entries := newTestMux(patterns)
+	b.Run("linear", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if e, _ := linearMatch(entries, path); e == nil {
+				b.Fatal("no match for", path)
+			}
+		}
+	})
+	b.Run("trie", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, h, _ := mux.match("GET", path); h == nil {
+				b.Fatal("no match for", path)
+			}
+		}
+	})
+}
+
+// BenchmarkServeMux500 compares the linear scan of patterns with the trie,
+// for 500 patterns, matching the last one registered.
+func BenchmarkServeMux500(b *testing.B) {
+	b.Run("static", func(b *testing.B) {
+		benchmarkRoutes(b, "^/static/route%d$", "/static/route499")
+	})
+	b.Run("regexp", func(b *testing.B) {
+		benchmarkRoutes(b, "^/api/resource%d/([0-9]+)/(json|xml)$",
+			"/api/resource499/42/json")
+	})
+}
+
+// discardWriter is a ResponseWriter that discards the response.
+type discardWriter struct{ h http.Header }
+
+func (w *discardWriter) Header() http.Header         { return w.h }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// benchmarkServeHTTP benchmarks serving path with a few typical routes.
+func benchmarkServeHTTP(b *testing.B, path string) {
+	mux := NewServeMux()
+	nop := func(w http.ResponseWriter, r *http.Request) {}
+	mux.HandleFunc("^/$", nop)
+	mux.HandleFunc("^/(csv|json|xml)/(.*)$",
+		func(w http.ResponseWriter, r *http.Request) { _ = Vars(r) })
+	mux.HandleFunc("^/metrics$", nop)
+	for _, p := range []string{"^/a$", "^/b/(.*)$",
+		"^/c/(?P<id>[0-9]+)$", "^/users/([0-9]+)/posts$"} {
+		mux.HandleFunc(p, nop)
+	}
+	w := &discardWriter{h: make(http.Header)}
+	r, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mux.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkServeHTTPLookup(b *testing.B)  { benchmarkServeHTTP(b, "/json/8.8.8.8") }
+func BenchmarkServeHTTPMetrics(b *testing.B) { benchmarkServeHTTP(b, "/metrics") }
+func BenchmarkServeHTTPIndex(b *testing.B)   { benchmarkServeHTTP(b, "/") }
@@ -0,0 +1,159 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package remux
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// urlTemplate is a pattern reversed into literal parts and the capturing
+// groups to be filled with parameters.
+type urlTemplate struct {
+	re    *regexp.Regexp
+	parts []urlPart
+	ncap  int
+}
+
+// urlPart is either a literal, or a capturing group whose value must
+// match re.
+type urlPart struct {
+	lit string
+	cap int // index of the group, starting at 1, or 0 for literals
+	re  *regexp.Regexp
+}
+
+// newURLTemplate reverses pattern into a template. Only a subset of
+// regular expressions is supported: literals, anchors, capturing groups
+// of any content, and optional or repeated parts, which are left out.
+func newURLTemplate(re *regexp.Regexp) (*urlTemplate, error) {
+	tree, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	t := &urlTemplate{re: re, ncap: re.NumSubexp()}
+	if !t.add(tree) {
+		return nil, fmt.Errorf("Can't build URLs for %q", re.String())
+	}
+	return t, nil
+}
+
+// add appends the parts of re to the template, and reports whether they
+// are supported.
+func (t *urlTemplate) add(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !t.add(sub) {
+				return false
+			}
+		}
+	case syntax.OpLiteral:
+		lit := string(re.Rune)
+		if re.Flags&syntax.FoldCase != 0 {
+			// The original case is lost, but any case matches.
+			lit = strings.ToLower(lit)
+		}
+		t.parts = append(t.parts, urlPart{lit: lit})
+	case syntax.OpCapture:
+		sub, err := regexp.Compile("^(?:" + re.Sub[0].String() + ")$")
+		if err != nil {
+			return false
+		}
+		t.parts = append(t.parts, urlPart{cap: re.Cap, re: sub})
+	case syntax.OpQuest, syntax.OpStar:
+		// Left out, unless they contain groups that take parameters.
+		return re.MaxCap() == 0
+	case syntax.OpBeginText, syntax.OpEndText, syntax.OpBeginLine,
+		syntax.OpEndLine, syntax.OpEmptyMatch:
+		// Nothing to add.
+	default:
+		return false
+	}
+	return true
+}
+
+// build fills the template with params, one for each capturing group.
+func (t *urlTemplate) build(params []string) (string, error) {
+	if len(params) != t.ncap {
+		return "", fmt.Errorf("Route %q takes %d parameters, got %d",
+			t.re.String(), t.ncap, len(params))
+	}
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.cap == 0 {
+			b.WriteString(p.lit)
+			continue
+		}
+		v := params[p.cap-1]
+		if !p.re.MatchString(v) {
+			return "", fmt.Errorf("Invalid parameter %d for route %q: %q",
+				p.cap, t.re.String(), v)
+		}
+		b.WriteString(v)
+	}
+	u := b.String()
+	if !t.re.MatchString(u) {
+		return "", fmt.Errorf("Can't build URLs for %q", t.re.String())
+	}
+	return u, nil
+}
+
+// Name gives a name to the registered pattern, for building URLs with
+// URLFor. It panics if the pattern is not registered, the name is already
+// taken, or URLs can't be built from the pattern.
+//
+// Patterns must be made of literals and capturing groups, which are
+// filled with parameters. Optional and repeated parts without groups, like
+// the slash in "^/users/?$", are left out of URLs, and case-insensitive
+// literals are written in lower case.
+func (mux *ServeMux) Name(name, pattern string) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	e := mux.m[pattern]
+	if e == nil {
+		panic("remux: unknown pattern " + pattern)
+	}
+	if mux.names[name] != nil {
+		panic("remux: multiple routes named " + name)
+	}
+	t, err := newURLTemplate(e.re)
+	if err != nil {
+		panic("remux: " + err.Error())
+	}
+	if mux.names == nil {
+		mux.names = make(map[string]*urlTemplate)
+	}
+	mux.names[name] = t
+}
+
+// URLFor returns the URL path of the route with the given name, with its
+// capturing groups filled with params, in order. Each parameter must match
+// its group. For example, with the pattern "^/(csv|json)/(.*)$" named
+// "lookup", URLFor("lookup", "json", "8.8.8.8") returns "/json/8.8.8.8".
+func (mux *ServeMux) URLFor(name string, params ...string) (string, error) {
+	mux.mu.RLock()
+	t := mux.names[name]
+	mux.mu.RUnlock()
+	if t == nil {
+		return "", fmt.Errorf("No route named %q", name)
+	}
+	return t.build(params)
+}
+
+// Name gives a name to the registered pattern of the group. See
+// ServeMux.Name.
+func (g *Group) Name(name, pattern string) {
+	g.mux.Name(name, g.pattern(pattern))
+}
+
+// URLFor returns the URL path of the route with the given name in the
+// DefaultServeMux. See ServeMux.URLFor.
+func URLFor(name string, params ...string) (string, error) {
+	return DefaultServeMux.URLFor(name, params...)
+}
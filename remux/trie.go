@@ -0,0 +1,83 @@
+// Copyright 2013 Alexandre Fiori
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package remux
+
+import (
+	"regexp/syntax"
+	"sort"
+)
+
+// trie indexes pattern entries by their literal prefix, so only the
+// patterns that can possibly match a path are evaluated. Patterns without
+// a literal prefix, including those not anchored with "^", are kept at
+// the root and evaluated for every path.
+type trie struct {
+	children map[byte]*trie
+	entries  []*muxEntry
+}
+
+// insert adds e to the trie under the given prefix.
+func (t *trie) insert(prefix string, e *muxEntry) {
+	for i := 0; i < len(prefix); i++ {
+		if t.children == nil {
+			t.children = make(map[byte]*trie)
+		}
+		c := t.children[prefix[i]]
+		if c == nil {
+			c = &trie{}
+			t.children[prefix[i]] = c
+		}
+		t = c
+	}
+	t.entries = append(t.entries, e)
+}
+
+// lookup returns the entries whose prefix is a prefix of path, in order
+// of registration.
+func (t *trie) lookup(path string) []*muxEntry {
+	l := t.entries
+	merged := false
+	for i := 0; i < len(path); i++ {
+		if t = t.children[path[i]]; t == nil {
+			break
+		}
+		if len(t.entries) == 0 {
+			continue
+		}
+		if len(l) == 0 {
+			l = t.entries
+			continue
+		}
+		if !merged {
+			// Copy before appending, l belongs to the trie.
+			l = append([]*muxEntry(nil), l...)
+			merged = true
+		}
+		l = append(l, t.entries...)
+	}
+	if merged {
+		sort.Slice(l, func(i, j int) bool { return l[i].n < l[j].n })
+	}
+	return l
+}
+
+// literalPrefix returns the literal string that all matches of pattern
+// begin with, or an empty string if the pattern is not anchored at the
+// beginning of the text or doesn't begin with a case-sensitive literal.
+// It also reports whether the pattern is static, matching that literal
+// string only, like "^/metrics$".
+func literalPrefix(pattern string) (prefix string, static bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil || re.Op != syntax.OpConcat || len(re.Sub) < 2 ||
+		re.Sub[0].Op != syntax.OpBeginText {
+		return "", false
+	}
+	lit := re.Sub[1]
+	if lit.Op != syntax.OpLiteral || lit.Flags&syntax.FoldCase != 0 {
+		return "", false
+	}
+	static = len(re.Sub) == 3 && re.Sub[2].Op == syntax.OpEndText
+	return string(lit.Rune), static
+}
@@ -8,28 +8,41 @@
 // Usage example:
 //
 //	func SSEHandler(w http.ResponseWriter, req *http.Request) {
-//	        conn, err := sse.ServeEvents(w)
+//	        s, err := sse.NewStream(w, req)
 //	        if err != nil {
 //	                http.Error(w, err.Error(), http.StatusInternalServerError)
 //	                return
 //	        }
-//	        defer conn.Close()
 //	        for i := 0; i < 10; i++ {
-//	                sse.SendEvent(conn, &sse.MessageEvent{Data: "Hello, world"})
-//	                time.Sleep(1 * time.Second)
+//	                if err := s.Send("", "Hello, world"); err != nil {
+//	                        return // client is gone
+//	                }
+//	                select {
+//	                case <-s.Done():
+//	                        return
+//	                case <-time.After(1 * time.Second):
+//	                }
 //	        }
 //	}
+//
+// ServeEvents and SendEvent provide the same on hijacked connections.
 package sse
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"strings"
 )
 
-var ErrNoHijack = errors.New("Server does not support hijacking")
+var (
+	ErrNoHijack = errors.New("Server does not support hijacking")
+	ErrNoFlush  = errors.New("Server does not support flushing")
+)
 
 // MessageEvent is the container of Server-Sent events (SSE), push notifications.
 type MessageEvent struct {
@@ -61,21 +74,91 @@ func ServeEvents(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
 // Browsers can handle these events in JavaScript:
 // http://www.w3schools.com/html/html5_serversentevents.asp
 func SendEvent(buf *bufio.ReadWriter, m *MessageEvent) (err error) {
+	if err = writeEvent(buf, m); err == nil {
+		err = buf.Flush()
+	}
+	return
+}
+
+// Line breaks in event streams are CRLF, CR or LF. They're normalized in
+// data, which is sent as multiple fields, and removed from other fields so
+// their values can't inject fields or events.
+var (
+	newlines      = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+	stripNewlines = strings.NewReplacer("\r", "", "\n", "")
+)
+
+// writeEvent writes m in the event stream format. Data with multiple
+// lines is sent as multiple data fields. The event is written at once, so
+// errors like a broken pipe are reported and no partial event is sent.
+func writeEvent(w io.Writer, m *MessageEvent) error {
+	var b strings.Builder
 	if m.Data != "" {
-		fmt.Fprintf(buf, "data: %s\n", m.Data)
+		data := newlines.Replace(m.Data)
+		for _, line := range strings.Split(data, "\n") {
+			fmt.Fprintf(&b, "data: %s\n", line)
+		}
 	}
 	if m.Event != "" {
-		fmt.Fprintf(buf, "event: %s\n", m.Event)
+		fmt.Fprintf(&b, "event: %s\n", stripNewlines.Replace(m.Event))
 	}
 	if m.Id != "" {
-		fmt.Fprintf(buf, "id: %s\n", m.Id)
+		fmt.Fprintf(&b, "id: %s\n", stripNewlines.Replace(m.Id))
 	}
 	if m.Retry >= 1 {
-		fmt.Fprintf(buf, "retry: %d\n", m.Retry)
+		fmt.Fprintf(&b, "retry: %d\n", m.Retry)
 	}
-	_, err = fmt.Fprintf(buf, "\n")
-	if err == nil {
-		err = buf.Flush()
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Stream is an event stream over a regular http.ResponseWriter, which must
+// support flushing. Unlike ServeEvents, it doesn't hijack the connection,
+// so it works with HTTP/2 and with wrappers like httpxtra.Handler.
+type Stream struct {
+	w   http.ResponseWriter
+	f   http.Flusher
+	ctx context.Context
+}
+
+// NewStream prepares the response for SSE, push notifications, and sends
+// the response headers to the client. It returns ErrNoFlush if w doesn't
+// support flushing.
+func NewStream(w http.ResponseWriter, r *http.Request) (*Stream, error) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return nil, ErrNoFlush
 	}
-	return
+	h := w.Header()
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("X-Accel-Buffering", "no") // for proxies like Nginx
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+	return &Stream{w: w, f: f, ctx: r.Context()}, nil
+}
+
+// Done returns a channel that's closed when the client disconnects.
+func (s *Stream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Send sends an event with the given name and data. The event name is
+// optional. It returns an error if the client is gone.
+func (s *Stream) Send(event, data string) error {
+	return s.SendEvent(&MessageEvent{Event: event, Data: data})
+}
+
+// SendEvent sends the message event m and flushes it to the client.
+// It returns an error if the client is gone.
+func (s *Stream) SendEvent(m *MessageEvent) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if err := writeEvent(s.w, m); err != nil {
+		return err
+	}
+	s.f.Flush()
+	return nil
 }
@@ -107,6 +107,9 @@ func main() {
 					Logger:   logger,
 					XHeaders: Config.HTTP.XHeaders,
 				},
+				ReadTimeout:  seconds(Config.Timeouts.Read),
+				WriteTimeout: seconds(Config.Timeouts.Write),
+				IdleTimeout:  seconds(Config.Timeouts.Idle),
 			}
 			log.Fatal(httpxtra.ListenAndServe(server))
 			//wg.Done()
@@ -117,8 +120,11 @@ func main() {
 		log.Printf("Starting HTTPS server on %s", Config.HTTPS.Addr)
 		go func() {
 			server := http.Server{
-				Addr:    Config.HTTPS.Addr,
-				Handler: httpxtra.Handler{Logger: logger},
+				Addr:         Config.HTTPS.Addr,
+				Handler:      httpxtra.Handler{Logger: logger},
+				ReadTimeout:  seconds(Config.Timeouts.Read),
+				WriteTimeout: seconds(Config.Timeouts.Write),
+				IdleTimeout:  seconds(Config.Timeouts.Idle),
 			}
 			log.Fatal(server.ListenAndServeTLS(
 				Config.HTTPS.CrtFile, Config.HTTPS.KeyFile))
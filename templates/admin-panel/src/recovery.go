@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/fiorix/go-web/httpxtra"
 	"github.com/gorilla/sessions"
 )
 
@@ -36,7 +37,7 @@ func RecoveryHandler(w http.ResponseWriter, r *http.Request) {
 		JSON(w, RecoveryResponse{Error: "InvalidEmail"})
 		return
 	}
-	if exists, err := UserExists(v.Email); err != nil {
+	if exists, err := UserExists(r.Context(), v.Email); err != nil {
 		httpError(w, 503, err)
 		return
 	} else if !exists {
@@ -68,7 +69,7 @@ func RecoveryHandler(w http.ResponseWriter, r *http.Request) {
 		map[string]string{
 			"ReplyTo": Config.SMTP.ReplyTo,
 			"Email":   v.Email,
-			"IP":      remoteIP(r),
+			"IP":      httpxtra.ClientIP(r),
 			"URL":     serverURL(r, true) + "recovery-confirm?q=" + hex,
 		})
 	if err != nil {
@@ -132,7 +133,7 @@ func RecoveryConfirmHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Get user from DB
-	u, err := GetUser(email)
+	u, err := GetUser(r.Context(), email)
 	if err != nil {
 		// TODO: check sql.ErrNoRows
 		httpError(w, 503, err)
@@ -141,7 +142,7 @@ func RecoveryConfirmHandler(w http.ResponseWriter, r *http.Request) {
 	newpw := sha1.New()
 	io.WriteString(newpw, v.Passwd)
 	u.Passwd = hex.EncodeToString(newpw.Sum(nil))
-	if err := UpdateUser(u); err != nil {
+	if err := UpdateUser(r.Context(), u); err != nil {
 		httpError(w, 503, err)
 		return
 	}
@@ -158,7 +159,7 @@ func RecoveryConfirmHandler(w http.ResponseWriter, r *http.Request) {
 		map[string]string{
 			"ReplyTo": Config.SMTP.ReplyTo,
 			"Email":   email,
-			"IP":      remoteIP(r),
+			"IP":      httpxtra.ClientIP(r),
 			"URL":     serverURL(r, true),
 		})
 	if err != nil {
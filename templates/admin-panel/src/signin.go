@@ -34,7 +34,7 @@ func SigninHandler(w http.ResponseWriter, r *http.Request) {
 		JSON(w, SigninResponse{Error: "InvalidEmail"})
 		return
 	}
-	u, err := GetUserWithPasswd(v.Email, v.Passwd)
+	u, err := GetUserWithPasswd(r.Context(), v.Email, v.Passwd)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			JSON(w, SigninResponse{Error: "InvalidEmail"})
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/fiorix/go-web/httpxtra"
 	"github.com/gorilla/sessions"
 )
 
@@ -66,7 +67,7 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Check if this user already exists in the db.
-	if exists, err := UserExists(v.Email); err != nil {
+	if exists, err := UserExists(r.Context(), v.Email); err != nil {
 		httpError(w, 503, err)
 		return
 	} else if exists {
@@ -90,7 +91,7 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 		map[string]string{
 			"ReplyTo": Config.SMTP.ReplyTo,
 			"Email":   v.Email,
-			"IP":      remoteIP(r),
+			"IP":      httpxtra.ClientIP(r),
 			"URL":     serverURL(r, true) + "signup-confirm?q=" + hex,
 		})
 	if err != nil {
@@ -159,7 +160,7 @@ func SignupConfirmHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Create user in the db, activated.
-	u, err := NewUser(email, v.Passwd, v.FullName, true)
+	u, err := NewUser(r.Context(), email, v.Passwd, v.FullName, true)
 	if err != nil {
 		// Look for MySQL #1062 (dup entry)
 		if strings.Contains(err.Error(), "#1062") {
@@ -182,7 +183,7 @@ func SignupConfirmHandler(w http.ResponseWriter, r *http.Request) {
 		map[string]string{
 			"ReplyTo": Config.SMTP.ReplyTo,
 			"Email":   email,
-			"IP":      remoteIP(r),
+			"IP":      httpxtra.ClientIP(r),
 			"URL":     serverURL(r, true),
 		})
 	if err != nil {
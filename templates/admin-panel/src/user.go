@@ -21,7 +21,7 @@ type UserIndexResponse struct {
 }
 
 func UserIndexHandler(w http.ResponseWriter, r *http.Request, s *sessions.Session) {
-	if u, err := GetUserById(s.Values["Id"].(int)); err != nil {
+	if u, err := GetUserById(r.Context(), s.Values["Id"].(int)); err != nil {
 		httpError(w, 503, err)
 	} else {
 		JSON(w, UserIndexResponse{
@@ -56,7 +56,7 @@ func UserSettingsHandler(w http.ResponseWriter, r *http.Request, s *sessions.Ses
 		httpError(w, 400, err)
 		return
 	}
-	u, err := GetUserById(s.Values["Id"].(int))
+	u, err := GetUserById(r.Context(), s.Values["Id"].(int))
 	if err != nil {
 		httpError(w, 503, err)
 		return
@@ -93,7 +93,7 @@ func UserSettingsHandler(w http.ResponseWriter, r *http.Request, s *sessions.Ses
 		changes++
 	}
 	if changes > 0 {
-		if err := UpdateUser(u); err != nil {
+		if err := UpdateUser(r.Context(), u); err != nil {
 			httpError(w, 503, err)
 			return
 		}
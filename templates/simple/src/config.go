@@ -8,6 +8,7 @@ import (
 	"encoding/xml"
 	"io/ioutil"
 	"path/filepath"
+	"time"
 )
 
 type ConfigData struct {
@@ -22,6 +23,11 @@ type ConfigData struct {
 		CrtFile string
 		KeyFile string
 	}
+	Timeouts struct {
+		Read  int `xml:"read,attr"`
+		Write int `xml:"write,attr"`
+		Idle  int `xml:"idle,attr"`
+	}
 	DocumentRoot string
 	MySQL        string
 	Redis        string
@@ -45,6 +51,11 @@ func ReadConfig(filename string) (*ConfigData, error) {
 	return cfg, nil
 }
 
+// seconds converts a number of seconds from the config file to a Duration.
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
+
 func relativePath(basedir string, path *string) {
 	p := *path
 	if p != "" && p[0] != '/' {
@@ -8,7 +8,10 @@
 
 package main
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+)
 
 type User struct {
 	Id       int
@@ -18,8 +21,11 @@ type User struct {
 	IsActive bool
 }
 
-func NewUser(email, passwd, fullname string, active bool) (*User, error) {
-	if _, err := MySQL.Exec(`
+// NewUser creates a user and returns it. Like the other queries, it takes
+// a context, usually the r.Context() of the request, so it's canceled when
+// the client goes away or the request times out.
+func NewUser(ctx context.Context, email, passwd, fullname string, active bool) (*User, error) {
+	if _, err := MySQL.ExecContext(ctx, `
 		insert into User (Email, Passwd, FullName, IsActive)
 		values (?, SHA1(?), ?, ?)`,
 		email,
@@ -29,12 +35,12 @@ func NewUser(email, passwd, fullname string, active bool) (*User, error) {
 	); err != nil {
 		return nil, err
 	}
-	return GetUser(email)
+	return GetUser(ctx, email)
 }
 
-func UserExists(email string) (bool, error) {
+func UserExists(ctx context.Context, email string) (bool, error) {
 	var count int
-	if err := MySQL.QueryRow(
+	if err := MySQL.QueryRowContext(ctx,
 		"select count(*) from User where Email=?", email,
 	).Scan(
 		&count,
@@ -46,9 +52,9 @@ func UserExists(email string) (bool, error) {
 }
 
 // TODO: cache
-func GetUser(email string) (*User, error) {
+func GetUser(ctx context.Context, email string) (*User, error) {
 	var u User
-	if err := MySQL.QueryRow(
+	if err := MySQL.QueryRowContext(ctx,
 		"select * from User where Email=?", email,
 	).Scan(
 		&u.Id,
@@ -63,9 +69,9 @@ func GetUser(email string) (*User, error) {
 }
 
 // TODO: cache
-func GetUserById(id int) (*User, error) {
+func GetUserById(ctx context.Context, id int) (*User, error) {
 	var u User
-	if err := MySQL.QueryRow(
+	if err := MySQL.QueryRowContext(ctx,
 		"select * from User where Id=?", id,
 	).Scan(
 		&u.Id,
@@ -79,9 +85,9 @@ func GetUserById(id int) (*User, error) {
 	return &u, nil
 }
 
-func GetUserWithPasswd(email, passwd string) (*User, error) {
+func GetUserWithPasswd(ctx context.Context, email, passwd string) (*User, error) {
 	var u User
-	if err := MySQL.QueryRow(
+	if err := MySQL.QueryRowContext(ctx,
 		"select * from User where Email=? and Passwd=SHA1(?)",
 		email,
 		passwd,
@@ -97,8 +103,8 @@ func GetUserWithPasswd(email, passwd string) (*User, error) {
 	return &u, nil
 }
 
-func DelUser(u *User) error {
-	if _, err := MySQL.Exec(
+func DelUser(ctx context.Context, u *User) error {
+	if _, err := MySQL.ExecContext(ctx,
 		"delete from Users where Id=?", u.Id,
 	); err != nil {
 		return err
@@ -106,8 +112,8 @@ func DelUser(u *User) error {
 	return nil
 }
 
-func UpdateUser(u *User) error {
-	if _, err := MySQL.Exec(
+func UpdateUser(ctx context.Context, u *User) error {
+	if _, err := MySQL.ExecContext(ctx,
 		"update User set Passwd=?, FullName=?, IsActive=? where Id=?",
 		u.Passwd,
 		u.FullName.String,
@@ -11,20 +11,9 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
-	"net"
 	"net/http"
 )
 
-// remoteIP returns the remote IP without the port number.
-func remoteIP(r *http.Request) string {
-	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err != nil {
-		return r.RemoteAddr // xheaders?
-	} else {
-		return ip
-	}
-	return "" // Go1.0
-}
-
 // serverURL returns the URL of the server based on the current request.
 func serverURL(r *http.Request, preferSSL bool) string {
 	var (